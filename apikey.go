@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrNoAPIKey is returned when no API key could be found for a source.
+var ErrNoAPIKey = errors.New("no API key found")
+
+// apiHosts maps source names to the host their API keys are stored under in ~/.netrc.
+var apiHosts = map[string]string{
+	"wordnik": "api.wordnik.com",
+	"mw":      "dictionaryapi.com",
+}
+
+// apiKey returns the API key for the provided source.
+// It tries these in order, returning the first key found:
+//  1. The GODICT_<SOURCE>_API_KEY environment variable
+//  2. The password of the source's machine in ~/.netrc (or $NETRC)
+//  3. The OS keyring, under the service "go-dict" and the source name as the account
+func apiKey(source string) (string, error) {
+	if key := os.Getenv(envName(source + "-api-key")); key != "" {
+		return key, nil
+	}
+	if host, ok := apiHosts[source]; ok {
+		if key, err := netrcPassword(host); err == nil && key != "" {
+			return key, nil
+		}
+	}
+	if key, err := keyringPassword(source); err == nil && key != "" {
+		return key, nil
+	}
+	return "", fmt.Errorf("%w for %s, set %s", ErrNoAPIKey, source, envName(source+"-api-key"))
+}
+
+// netrcPassword returns the password for host from the user's netrc file.
+func netrcPassword(host string) (string, error) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Split(bufio.ScanWords)
+	inHost := false
+	for sc.Scan() {
+		switch sc.Text() {
+		case "machine":
+			if !sc.Scan() {
+				break
+			}
+			inHost = sc.Text() == host
+		case "default":
+			inHost = true
+		case "password":
+			if !sc.Scan() {
+				break
+			}
+			if inHost {
+				return sc.Text(), nil
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrNoAPIKey
+}
+
+// keyringPassword returns the password stored in the OS keyring for the source.
+// It uses secret-tool on Linux and the BSDs, and security on macOS.
+// Other platforms aren't supported and always return an error.
+func keyringPassword(source string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", "go-dict", "-a", source, "-w")
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", "go-dict", "account", source)
+	default:
+		return "", errors.New("keyring not supported on " + runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
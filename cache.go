@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL         = 7 * 24 * time.Hour // How long cached lookups are used for by default
+	defaultNegativeCacheTTL = time.Hour          // How long words that weren't found are remembered by default
+)
+
+// cacheVersion is the version of the format entries are cached in. It must be
+// increased whenever cachedEntry changes in a way that older entries wouldn't be
+// read back correctly, like adding a field that's always set. Entries with any
+// other version are ignored, so they're looked up again and replaced.
+const cacheVersion = 3
+
+// cachedDefinition is how a ctxDefinition is stored in the cache.
+type cachedDefinition struct {
+	Dict        string   `json:"dict"`
+	Rank        int      `json:"rank"`
+	SourceURL   string   `json:"source_url"`
+	Homograph   int      `json:"homograph,omitempty"`
+	Attribution string   `json:"attribution,omitempty"`
+	WordType    string   `json:"word_type"`
+	Label       string   `json:"label,omitempty"`
+	Text        string   `json:"text"`
+	Examples    []string `json:"examples,omitempty"`
+	Origin      *[3]int  `json:"origin,omitempty"` // heading, list, rank
+}
+
+// cachedRelation is how a relation is stored in the cache.
+type cachedRelation struct {
+	Kind     string   `json:"kind"`
+	WordType string   `json:"word_type,omitempty"`
+	Words    []string `json:"words"`
+}
+
+// cachedEntry is how an entry is stored in the cache.
+type cachedEntry struct {
+	Version        int                `json:"version"`
+	Word           string             `json:"word"`
+	URL            string             `json:"url"`
+	FormOf         string             `json:"form_of,omitempty"`
+	Defs           []cachedDefinition `json:"defs"`
+	Related        []cachedRelation   `json:"related,omitempty"`
+	Pronunciations map[int]string     `json:"pronunciations,omitempty"`
+}
+
+// toCached converts an entry to the form it's stored in the cache in.
+func toCached(e *entry) *cachedEntry {
+	ce := &cachedEntry{
+		Version:        cacheVersion,
+		Word:           e.word,
+		URL:            e.url,
+		FormOf:         e.formOf,
+		Defs:           make([]cachedDefinition, len(e.defs)),
+		Pronunciations: e.pronunciations,
+	}
+	for i, cD := range e.defs {
+		ce.Defs[i] = cachedDefinition{
+			Dict:        cD.dict,
+			Rank:        cD.rank,
+			SourceURL:   cD.sourceURL,
+			Homograph:   cD.homograph,
+			Attribution: cD.attribution,
+			WordType:    cD.def.wordType,
+			Label:       cD.def.label,
+			Text:        cD.def.text,
+			Examples:    cD.def.examples,
+		}
+		if o := cD.def.origin; o != nil {
+			ce.Defs[i].Origin = &[3]int{o.heading, o.list, o.rank}
+		}
+	}
+	for _, rel := range e.related {
+		ce.Related = append(ce.Related, cachedRelation{Kind: rel.kind, WordType: rel.wordType, Words: rel.words})
+	}
+	return ce
+}
+
+// entry converts the cached entry back to an entry.
+func (ce *cachedEntry) entry() *entry {
+	e := &entry{
+		word:           ce.Word,
+		url:            ce.URL,
+		formOf:         ce.FormOf,
+		defs:           make([]ctxDefinition, len(ce.Defs)),
+		pronunciations: ce.Pronunciations,
+	}
+	for i, cd := range ce.Defs {
+		e.defs[i] = ctxDefinition{
+			dict:        cd.Dict,
+			rank:        cd.Rank,
+			sourceURL:   cd.SourceURL,
+			homograph:   cd.Homograph,
+			attribution: cd.Attribution,
+			def: definition{
+				wordType: cd.WordType,
+				label:    cd.Label,
+				text:     cd.Text,
+				examples: cd.Examples,
+			},
+		}
+		if o := cd.Origin; o != nil {
+			e.defs[i].def.origin = &origin{heading: o[0], list: o[1], rank: o[2]}
+		}
+	}
+	for _, rel := range ce.Related {
+		e.related = append(e.related, relation{kind: rel.Kind, wordType: rel.WordType, words: rel.Words})
+	}
+	return e
+}
+
+// cache stores looked up entries on disk, one file per source and word.
+// Words that weren't found are cached too, in files with a .notfound extension,
+// so they can be told apart from entries. They're empty, or have a JSON array of
+// the words the source suggested instead.
+//
+// Files are written atomically, by renaming a finished temporary file into place,
+// so other processes never read a partly written one. Within go-dict, reads and
+// writes of the same word are also serialized by a lock for each file.
+type cache struct {
+	dir         string
+	ttl         time.Duration // How long entries are used for after they're written
+	negativeTTL time.Duration // How long not found results are used for
+
+	mu    sync.Mutex               // Guards locks
+	locks map[string]*sync.RWMutex // Keyed by path, without the extension
+}
+
+// newCache returns a cache in go-dict's cache directory.
+func newCache(ttl, negativeTTL time.Duration) (*cache, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &cache{dir: filepath.Join(dir, "entries"), ttl: ttl, negativeTTL: negativeTTL}, nil
+}
+
+// path returns the file the entry for the word from the source is stored in,
+// without the extension. Thesaurus lookups are stored separately from normal ones,
+// since they don't have the definitions.
+func (c *cache) path(source, w string, thesaurus bool) string {
+	if thesaurus {
+		source += "-thesaurus"
+	}
+	return filepath.Join(c.dir, source, url.PathEscape(w))
+}
+
+// lock returns the lock for the cache files at path.
+func (c *cache) lock(path string) *sync.RWMutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.locks == nil {
+		c.locks = make(map[string]*sync.RWMutex)
+	}
+	l, ok := c.locks[path]
+	if !ok {
+		l = &sync.RWMutex{}
+		c.locks[path] = l
+	}
+	return l
+}
+
+// writeFileAtomic writes data to the file at path by writing it to a temporary
+// file in the same directory and renaming that over path.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// fresh reports whether the file at path exists and is younger than ttl.
+func fresh(path string, ttl time.Duration) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) <= ttl
+}
+
+// get returns the cached entry for the word from the source, if there's one
+// that hasn't expired.
+func (c *cache) get(source, w string, thesaurus bool) (*entry, bool) {
+	l := c.lock(c.path(source, w, thesaurus))
+	l.RLock()
+	defer l.RUnlock()
+	path := c.path(source, w, thesaurus) + ".json"
+	if !fresh(path, c.ttl) {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var ce cachedEntry
+	if err := json.Unmarshal(data, &ce); err != nil {
+		debugLog.Printf("ignoring invalid cache file %s: %v", path, err)
+		return nil, false
+	}
+	if ce.Version != cacheVersion {
+		debugLog.Printf("ignoring cache file %s from version %d of the format, not %d", path, ce.Version, cacheVersion)
+		return nil, false
+	}
+	return ce.entry(), true
+}
+
+// put stores the entry for the word from the source in the cache, replacing
+// any not found result for it.
+func (c *cache) put(source, w string, thesaurus bool, e *entry) error {
+	path := c.path(source, w, thesaurus)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(toCached(e))
+	if err != nil {
+		return err
+	}
+	l := c.lock(path)
+	l.Lock()
+	defer l.Unlock()
+	os.Remove(path + ".notfound")
+	return writeFileAtomic(path+".json", data)
+}
+
+// notFound reports whether the source recently didn't have the word, and returns
+// the words it suggested instead, if any.
+func (c *cache) notFound(source, w string, thesaurus bool) ([]string, bool) {
+	path := c.path(source, w, thesaurus)
+	l := c.lock(path)
+	l.RLock()
+	defer l.RUnlock()
+	path += ".notfound"
+	if !fresh(path, c.negativeTTL) {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var suggestions []string
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &suggestions); err != nil {
+			debugLog.Printf("ignoring invalid suggestions in cache file %s: %v", path, err)
+			suggestions = nil
+		}
+	}
+	return suggestions, true
+}
+
+// putNotFound records that the source doesn't have the word, along with the words
+// it suggested instead.
+func (c *cache) putNotFound(source, w string, thesaurus bool, suggestions []string) error {
+	path := c.path(source, w, thesaurus)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var data []byte
+	if len(suggestions) > 0 {
+		var err error
+		data, err = json.Marshal(suggestions)
+		if err != nil {
+			return err
+		}
+	}
+	l := c.lock(path)
+	l.Lock()
+	defer l.Unlock()
+	return writeFileAtomic(path+".notfound", data)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCache returns a cache in a temporary directory.
+func testCache(t *testing.T) *cache {
+	t.Helper()
+	return &cache{dir: filepath.Join(t.TempDir(), "entries"), ttl: time.Hour, negativeTTL: time.Hour}
+}
+
+// cacheEntry returns an entry for the word with n definitions, all saying which version of it they're from.
+func cacheEntry(w string, version, n int) *entry {
+	e := &entry{word: w, url: "https://example.com/" + w}
+	for i := 0; i < n; i++ {
+		e.defs = append(e.defs, ctxDefinition{
+			dict: "Test",
+			rank: i,
+			def:  definition{wordType: "noun", text: fmt.Sprintf("Version %d, definition %d.", version, i)},
+		})
+	}
+	return e
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	c := testCache(t)
+	if _, ok := c.get("wordnik", "run", false); ok {
+		t.Fatal("an empty cache has an entry")
+	}
+	want := cacheEntry("run", 1, 3)
+	want.defs[0].def.origin = &origin{heading: 0, list: 1, rank: 2}
+	if err := c.put("wordnik", "run", false, want); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c.get("wordnik", "run", false)
+	if !ok {
+		t.Fatal("the entry wasn't cached")
+	}
+	if len(got.defs) != 3 || got.defs[2].def.text != want.defs[2].def.text || got.defs[2].rank != 2 {
+		t.Errorf("cached definitions are %+v, want %+v", got.defs, want.defs)
+	}
+	if o := got.defs[0].def.origin; o == nil || *o != *want.defs[0].def.origin {
+		t.Errorf("cached origin is %v, want %v", o, want.defs[0].def.origin)
+	}
+	if got.defs[1].def.origin != nil {
+		t.Errorf("a definition without an origin has %v", got.defs[1].def.origin)
+	}
+	if _, ok := c.get("wordnik", "run", true); ok {
+		t.Error("the thesaurus lookup shares the entry of the normal one")
+	}
+}
+
+// Run with -race, this checks that concurrent lookups of the same word never
+// read an entry that's half written.
+func TestCacheConcurrent(t *testing.T) {
+	c := testCache(t)
+	// Another process using the same cache, which doesn't share the locks
+	other := &cache{dir: c.dir, ttl: c.ttl, negativeTTL: c.negativeTTL}
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*n)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			cc := c
+			if i%2 == 1 {
+				cc = other
+			}
+			// Big enough that a torn write would be noticed
+			if err := cc.put("wordnik", "run", false, cacheEntry("run", i, 200)); err != nil {
+				errs <- err
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			cc := c
+			if i%2 == 1 {
+				cc = other
+			}
+			for j := 0; j < 5; j++ {
+				e, ok := cc.get("wordnik", "run", false)
+				if !ok {
+					continue
+				}
+				if len(e.defs) != 200 {
+					errs <- fmt.Errorf("read an entry with %d definitions, want 200", len(e.defs))
+					return
+				}
+				var version, def int
+				fmt.Sscanf(e.defs[0].def.text, "Version %d, definition %d.", &version, &def)
+				if want := fmt.Sprintf("Version %d, definition 199.", version); e.defs[199].def.text != want {
+					errs <- fmt.Errorf("read an entry mixing versions: %q and %q", e.defs[0].def.text, e.defs[199].def.text)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if _, ok := c.get("wordnik", "run", false); !ok {
+		t.Error("nothing was cached")
+	}
+	matches, _ := filepath.Glob(filepath.Join(c.dir, "wordnik", ".tmp-*"))
+	if len(matches) > 0 {
+		t.Errorf("temporary files were left behind: %v", matches)
+	}
+}
+
+func TestCacheOldVersion(t *testing.T) {
+	c := testCache(t)
+	path := c.path("wordnik", "run", false) + ".json"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, old := range []string{
+		// From before there were versions, an older version, and a newer one
+		`{"word":"run","url":"https://example.com/run","defs":[{"dict":"Test","rank":0,"word_type":"noun","text":"Old."}]}`,
+		`{"version":1,"word":"run","url":"https://example.com/run","defs":[{"dict":"Test","rank":0,"word_type":"noun","text":"Old."}]}`,
+		fmt.Sprintf(`{"version":%d,"word":"run","defs":[]}`, cacheVersion+1),
+	} {
+		if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if e, ok := c.get("wordnik", "run", false); ok {
+			t.Errorf("old cache entry %s was used: %+v", old, e)
+		}
+	}
+}
+
+func TestCacheOldVersionRefetched(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write(fixture(t, "wordnik/walk.html"))
+	}))
+	defer ts.Close()
+	isolate(t)
+	c, err := newCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := c.path("wordnik", "walk", false) + ".json"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := `{"version":1,"word":"walk","defs":[{"dict":"Test","rank":0,"word_type":"noun","text":"Stale."}]}`
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := func() string {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"--no-color", "walk"}, map[string]string{"wordnik": ts.URL + "/words/"}, &stdout, &stderr); code != 0 {
+			t.Fatalf("exit status %d: %s", code, stderr.String())
+		}
+		return stdout.String()
+	}
+	want := "walk\nWiktionary\nverb  To move on foot at a pace slower than a run.\n\n"
+	if got := lookup(); got != want {
+		t.Errorf("stdout is %q, want %q", got, want)
+	}
+	// The entry was replaced, so it's not fetched again
+	if got := lookup(); got != want {
+		t.Errorf("stdout from the cache is %q, want %q", got, want)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("wordnik was asked for the word %d times, want 1", n)
+	}
+}
+
+func TestCacheNotFoundSuggestions(t *testing.T) {
+	var requests int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/words/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Redirect(w, r, "/search?query=colour", http.StatusFound)
+	})
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture(t, "wordnik/search.html"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	isolate(t)
+
+	lookup := func() string {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"--json", "colour"}, map[string]string{"wordnik": ts.URL + "/words/"}, &stdout, &stderr); code != 1 {
+			t.Fatalf("exit status %d, want 1: %s", code, stderr.String())
+		}
+		return stdout.String()
+	}
+	want := `{"word":"colour","error":"not_found","suggestions":["color","colours","colour bar"]}` + "\n"
+	if got := lookup(); got != want {
+		t.Errorf("stdout is %q, want %q", got, want)
+	}
+	// The second time it's not found from the cache, with the same suggestions
+	if got := lookup(); got != want {
+		t.Errorf("stdout from the cache is %q, want %q", got, want)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("wordnik was asked for the word %d times, want 1", n)
+	}
+}
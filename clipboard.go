@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// clipboardReaders are the commands that print the clipboard, in the order they're tried.
+var clipboardReaders = map[string][][]string{
+	"darwin":  {{"pbpaste"}},
+	"windows": {{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"}},
+	"linux":   {{"wl-paste", "--no-newline"}, {"xclip", "-selection", "clipboard", "-o"}, {"xsel", "--clipboard", "--output"}},
+}
+
+// clipboardWriters are the commands that copy their stdin to the clipboard, in the order they're tried.
+var clipboardWriters = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip.exe"}},
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard", "-i"}, {"xsel", "--clipboard", "--input"}},
+}
+
+// errNoClipboard is returned when none of the clipboard commands for the platform work.
+var errNoClipboard = errors.New("couldn't read the clipboard, install wl-clipboard, xclip or xsel")
+
+// errNoClipboardWrite is returned when none of the commands that copy to the clipboard work.
+var errNoClipboardWrite = errors.New("couldn't copy to the clipboard, install wl-clipboard, xclip or xsel")
+
+// readClipboard returns the text on the clipboard, using the first command for the platform that works.
+func readClipboard() (string, error) {
+	cmds := clipboardReaders[runtime.GOOS]
+	if cmds == nil {
+		cmds = clipboardReaders["linux"] // The BSDs use the same tools
+	}
+	for _, args := range cmds {
+		out, err := exec.Command(args[0], args[1:]...).Output()
+		if err == nil {
+			return string(out), nil
+		}
+	}
+	return "", errNoClipboard
+}
+
+// writeClipboard copies the text to the clipboard, using the first command for the platform that works.
+func writeClipboard(text string) error {
+	cmds := clipboardWriters[runtime.GOOS]
+	if cmds == nil {
+		cmds = clipboardWriters["linux"]
+	}
+	for _, args := range cmds {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+	}
+	return errNoClipboardWrite
+}
+
+// notifyScript is the AppleScript notify runs with osascript. The title and body are
+// its arguments, rather than part of the script, so they can't change what it does.
+var notifyScript = []string{
+	"on run argv",
+	"display notification (item 2 of argv) with title (item 1 of argv)",
+	"end run",
+}
+
+// notifyCommand returns the command that shows a desktop notification on goos,
+// which is notify-send, or osascript on macOS.
+func notifyCommand(goos, title, body string) (*exec.Cmd, error) {
+	switch goos {
+	case "darwin":
+		var args []string
+		for _, line := range notifyScript {
+			args = append(args, "-e", line)
+		}
+		return exec.Command("osascript", append(args, title, body)...), nil
+	case "windows":
+		return nil, errors.New("notifications aren't supported on windows")
+	}
+	return exec.Command("notify-send", title, body), nil
+}
+
+// notify shows a desktop notification, using notify-send, or osascript on macOS.
+func notify(title, body string) error {
+	cmd, err := notifyCommand(runtime.GOOS, title, body)
+	if err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+// singleWordRe matches clipboard text that's a single word, which is all
+// --watch-clipboard looks up, so copying a paragraph doesn't define it.
+var singleWordRe = regexp.MustCompile(`^[\p{L}][\p{L}'-]*$`)
+
+// watchClipboard polls the clipboard every interval, calling fn with any new single
+// word copied, until ctx is done. Whatever is on the clipboard when it starts is ignored.
+func watchClipboard(ctx context.Context, interval time.Duration, fn func(w string)) error {
+	last, err := readClipboard()
+	if err != nil {
+		return err
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		text, err := readClipboard()
+		if err != nil {
+			debugLog.Printf("reading the clipboard failed: %v", err)
+			continue
+		}
+		if text == last {
+			continue
+		}
+		last = text
+		if w := strings.TrimSpace(text); singleWordRe.MatchString(w) {
+			fn(w)
+		}
+	}
+}
+
+// runClipboardWatch defines each word copied to the clipboard, for --watch-clipboard.
+// With --notify the top definition is shown as a desktop notification, otherwise
+// the word is written to out like any other.
+func runClipboardWatch(srcs []Source, c *cache, names map[string]string, opts *options, out, stderr io.Writer) int {
+	fmt.Fprintln(stderr, "Watching the clipboard, press Ctrl-C to stop.")
+	err := watchClipboard(context.Background(), opts.clipboardInterval, func(w string) {
+		if !opts.caseSensitive {
+			w = strings.ToLower(w)
+		}
+		r := lookupWord(context.Background(), w, srcs, c, opts)
+		if r.err != nil {
+			fmt.Fprintf(stderr, "go-dict: %s: %v\n", w, r.err)
+			return
+		}
+		if opts.notify {
+			sanitizeEntry(r.e)
+			body := "(no definitions)"
+			if cD := glossDefinition(r.e.defs, opts.dictPriority); cD != nil {
+				body = cD.def.text
+			}
+			if err := notify(w, body); err != nil {
+				fmt.Fprintf(stderr, "go-dict: couldn't show a notification: %v\n", err)
+			}
+			return
+		}
+		var buf bytes.Buffer
+		writeEntry(&buf, r.e, names, opts)
+		out.Write(buf.Bytes())
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, "go-dict:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotifyCommand(t *testing.T) {
+	body := `Text that ends the string" & do shell script "echo`
+	tests := []struct {
+		goos string
+		want []string
+	}{
+		{"darwin", []string{"osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			"run", body}},
+		{"linux", []string{"notify-send", "run", body}},
+	}
+	for _, tt := range tests {
+		cmd, err := notifyCommand(tt.goos, "run", body)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.goos, err)
+		}
+		if !reflect.DeepEqual(cmd.Args, tt.want) {
+			t.Errorf("%s: command is %q, want %q", tt.goos, cmd.Args, tt.want)
+		}
+	}
+	if _, err := notifyCommand("windows", "run", body); err == nil {
+		t.Error("windows has a notification command")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/gookit/color.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// config holds the settings from the config file.
+type config struct {
+	// DictionaryNames maps full dictionary names to the short names --trim-dictionary-names uses.
+	// These are added to shortDictNames, replacing any that have the same full name.
+	DictionaryNames map[string]string `json:"dictionary_names"`
+	// DictionaryAliases maps names chosen by the user to the dictionaries they stand
+	// for, for use with --definitions-from and --dict-priority.
+	DictionaryAliases map[string]string `json:"dictionary_aliases"`
+	// ThemePreset is the name of the theme from themePresets that Theme changes, if not empty.
+	ThemePreset string `json:"theme_preset"`
+	// Theme maps parts of the output to the colors to show them in, see defaultTheme.
+	Theme map[string]string `json:"theme"`
+
+	theme map[string]color.Style // The parsed ThemePreset and Theme
+}
+
+// defaultConfigPath returns where the config file is when --config isn't given.
+func defaultConfigPath() string {
+	dir, err := configDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// loadConfig reads the JSON config file at path.
+// A missing file isn't an error, it just results in an empty config.
+func loadConfig(path string) (*config, error) {
+	conf := &config{}
+	if path == "" {
+		return conf, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return conf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	if conf.ThemePreset != "" || conf.Theme != nil {
+		base := defaultTheme
+		if conf.ThemePreset != "" {
+			base, err = presetTheme(conf.ThemePreset)
+			if err != nil {
+				return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+			}
+		}
+		conf.theme, err = parseTheme(conf.Theme, base)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+	}
+	return conf, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"gopkg.in/gookit/color.v1"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file with the contents to a temporary directory.
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigTheme(t *testing.T) {
+	conf, err := loadConfig(writeConfig(t, `{"theme": {"word": "bold bg_blue", "label": "lightRed"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := conf.theme["word"], color.New(color.Bold, color.BgBlue); !reflect.DeepEqual(got, want) {
+		t.Errorf("the word style is %v, want %v", got, want)
+	}
+	if got, want := conf.theme["example"], defaultTheme["example"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("the example style was changed to %v, want %v", got, want)
+	}
+}
+
+func TestLoadConfigInvalidTheme(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string // Parts of the error
+	}{
+		{
+			name:   "color",
+			config: `{"theme": {"word": "bold blurple"}}`,
+			want:   []string{`unknown color "blurple" for theme part "word"`, "valid colors are: ", "bg_blue", "bold", "lightRed"},
+		},
+		{
+			name:   "part",
+			config: `{"theme": {"wrod": "bold"}}`,
+			want:   []string{`unknown theme part "wrod"`, "valid parts are: ", "word"},
+		},
+		{
+			name:   "preset",
+			config: `{"theme_preset": "neon"}`,
+			want:   []string{`unknown theme preset "neon"`, "valid presets are: "},
+		},
+	}
+	for _, tt := range tests {
+		path := writeConfig(t, tt.config)
+		_, err := loadConfig(path)
+		if err == nil {
+			t.Errorf("%s: the config was accepted", tt.name)
+			continue
+		}
+		for _, want := range append(tt.want, path) {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q doesn't have %q", tt.name, err, want)
+			}
+		}
+	}
+}
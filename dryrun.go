@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// plannerSource is a Source that can tell which requests looking up a word would
+// make without making them, for --dry-run.
+type plannerSource interface {
+	Source
+	// PlannedRequests returns the URLs looking up the word requests, with any
+	// API key left out of them.
+	PlannedRequests(w string) []string
+}
+
+// dryRun writes the requests that looking up the words would make to out, one
+// per line, followed by how many there are, without making any of them.
+// Words that are repeated are only counted once, and a source that has a word in
+// the cache doesn't need to request it, the same as when looking them up.
+func dryRun(words []string, srcs []Source, c *cache, opts *options, out io.Writer) {
+	seen := make(map[string]bool)
+	unique := 0
+	requests := 0
+	for _, w := range words {
+		w = strings.Join(strings.Fields(w), " ")
+		if !opts.caseSensitive {
+			w = strings.ToLower(w)
+		}
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		unique++
+		for _, src := range srcs {
+			ps, ok := src.(plannerSource)
+			if !ok {
+				continue
+			}
+			if c != nil {
+				if _, ok := c.get(src.Name(), w, opts.thesaurus); ok {
+					continue
+				}
+			}
+			for _, u := range ps.PlannedRequests(w) {
+				fmt.Fprintf(out, "%s: GET %s\n", src.Name(), u)
+				requests++
+			}
+		}
+	}
+	fmt.Fprintf(out, "%s for %s\n", pluralize(requests, "request"), pluralize(unique, "word"))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// clearScreen moves the cursor to the top left of the terminal and clears it.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// pickWord returns a word for --random, or for --wotd if wotd is true, from the
+// first source that can pick one. Sources that fail are skipped for the next one.
+func pickWord(ctx context.Context, srcs []Source, wotd bool) (string, error) {
+	var errs []string
+	for _, src := range srcs {
+		var w string
+		var err error
+		if ws, ok := src.(wotdSource); wotd && ok {
+			w, err = ws.WordOfTheDay(ctx)
+		} else if rs, ok := src.(randomSource); !wotd && ok {
+			w, err = rs.RandomWord(ctx)
+		} else {
+			continue
+		}
+		if err == nil {
+			return w, nil
+		}
+		debugLog.Printf("%s: picking a word failed: %v", src.Name(), err)
+		errs = append(errs, src.Name()+": "+err.Error())
+	}
+	if len(errs) > 0 {
+		return "", errors.New(strings.Join(errs, "; "))
+	}
+	if wotd {
+		return "", errors.New("none of the sources have a word of the day")
+	}
+	return "", errors.New("none of the sources can pick a random word")
+}
+
+// runFeed shows a random word or the word of the day, for --random and --wotd.
+// With --watch it runs until interrupted, clearing the terminal and showing
+// a new word every interval.
+func runFeed(srcs []Source, c *cache, names map[string]string, opts *options, stdout, stderr io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	show := func() bool {
+		w, err := pickWord(ctx, srcs, opts.wotd)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintln(stderr, "go-dict:", err)
+			}
+			return false
+		}
+		if !opts.caseSensitive {
+			w = strings.ToLower(w)
+		}
+		r := lookupWord(ctx, w, srcs, c, opts)
+		if r.err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(stderr, "go-dict: %s: %v\n", w, r.err)
+			}
+			return false
+		}
+		var buf bytes.Buffer
+		if opts.watch > 0 && isTerminal(stdout) {
+			buf.WriteString(clearScreen)
+		}
+		writeEntry(&buf, r.e, names, opts)
+		stdout.Write(buf.Bytes())
+		return true
+	}
+	if opts.watch == 0 {
+		if !show() {
+			return 1
+		}
+		return 0
+	}
+	t := time.NewTicker(opts.watch)
+	defer t.Stop()
+	for {
+		show()
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-t.C:
+		}
+	}
+}
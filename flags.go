@@ -0,0 +1,509 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/text/language"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// options holds the settings parsed from the command line flags.
+type options struct {
+	dedupWithinDict    bool // Remove duplicate definition texts within each dictionary
+	sortByCount        bool // Order dictionaries by how many definitions they have
+	noWordType         bool // Hide the word type column
+	noColor            bool
+	examples           bool          // Show the examples under each definition
+	onlyWithExamples   bool          // Drop definitions that have no examples
+	thesaurus          bool          // Show related words instead of definitions
+	compact            bool          // Omit the dictionary header when there's only one dictionary
+	best               int           // Show only this many of the best definitions across all dictionaries
+	timeout            time.Duration // Per word lookup timeout, zero means no timeout
+	head               int           // Show only the first N definitions of each dictionary
+	tail               int           // Show only the last N definitions of each dictionary
+	json               bool          // Output JSON instead of formatted text
+	csv                bool          // Output CSV instead of formatted text
+	trimDictNames      bool          // Use short dictionary names
+	configPath         string
+	sources            string                  // Comma separated list of sources to use
+	debug              bool                    // Print debug messages to stderr
+	explain            bool                    // Show where each definition was scraped from
+	caseSensitive      bool                    // Don't lowercase words before looking them up
+	groupBy            string                  // Name of the grouping strategy
+	grouper            grouper                 // The grouping strategy groupBy names
+	dictPriority       []string                // Dictionaries to show first, in order
+	gloss              bool                    // Show a single definition line per word
+	failFast           bool                    // Stop at the first error
+	networkFatal       bool                    // Stop at the first network error
+	check              bool                    // Only check whether words exist
+	offline            bool                    // Only use the bundled dictionary
+	anki               bool                    // Output an Anki import file
+	statsByPOS         bool                    // Show part of speech counts instead of definitions
+	locale             language.Tag            // Locale to sort alphabetically in
+	file               string                  // File to read words from
+	prefetch           bool                    // Cache words without printing them
+	noCache            bool                    // Disable the cache
+	cacheTTL           time.Duration           // How long cached lookups are used for
+	concurrency        int                     // Maximum number of words looked up at once
+	bannerCount        bool                    // Show the definition count after the word
+	interactive        bool                    // Read words from a prompt
+	watchClipboard     bool                    // Define words copied to the clipboard
+	clipboardInterval  time.Duration           // How often the clipboard is checked
+	notify             bool                    // Show definitions as notifications
+	columns            int                     // Maximum number of columns of definitions
+	termWidth          int                     // Width of the terminal being written to
+	negativeCacheTTL   time.Duration           // How long not found results are cached for
+	width              int                     // Width to wrap definitions to, 0 to not wrap them
+	wrapIndent         int                     // Spaces to indent wrapped lines with, or wrapIndentColumn
+	maxTotal           int                     // Maximum definitions shown in total
+	headers            headerList              // Extra headers sent with every request
+	summaryWidth       int                     // Width gloss definitions are truncated to
+	attribution        bool                    // Show the attribution of each dictionary
+	jsonMinimal        bool                    // Output JSON with only the definition texts
+	lang               string                  // ISO 639-1 code of the language definitions must be in, if not empty
+	langConfidence     float64                 // How confident language detection must be to drop a definition
+	random             bool                    // Look up a random word
+	wotd               bool                    // Look up the word of the day
+	watch              time.Duration           // How often --random and --wotd show a new word, 0 to show one and exit
+	fallback           bool                    // Try the sources one at a time, for --source-order
+	stopOnNetworkError bool                    // Don't move on to the next source after a network error
+	number             func(d *definition) int // Returns the number to show before each definition, if not nil
+	frequency          bool                    // Show how common words are
+	primary            bool                    // Only show the dictionary with the most definitions
+	exampleCounts      bool                    // Show how many examples each definition has
+	caCert             string                  // Path of extra CA certificates to trust
+	insecure           bool                    // Don't verify HTTPS certificates
+	definitionsFrom    []string                // Dictionaries to only show definitions from
+	maxResponseSize    byteSize                // The biggest response body that's read, 0 for no limit
+	sortWords          string                  // Order to output words in, input or alpha
+	glossary           string                  // Format of the --glossary document, markdown or text, empty if not used
+	glossaryDefs       int                     // Definitions shown for each term of the glossary
+	reverse            string                  // Description of the meaning of the words to find, if not empty
+	reverseLimit       int                     // Most words --reverse finds
+	defineCandidates   bool                    // Look up the words --reverse finds
+	jitter             time.Duration           // Longest random wait before each word is looked up
+	themePreview       bool                    // Show a sample entry in each preset theme
+	copy               bool                    // Copy the output to the clipboard too
+	limitDicts         int                     // Show only the first N groups of definitions, 0 for all of them
+	acceptLanguage     string                  // Accept-Language header to send to wordnik, if not empty
+	separator          string                  // What's between words: blank, rule or none
+	agreement          bool                    // Record which sources define the word the same way
+	truncateExamples   int                     // Cut examples down to this many characters, 0 for no limit
+	dryRun             bool                    // Print the requests that would be made instead of making them
+	lemmatize          bool                    // Look up the base form of words that aren't found
+	pager              bool                    // Show output that's too long for the terminal in a pager
+	pagerThreshold     int                     // How many lines of output need the pager, 0 for the terminal height
+	color              string                  // When to use colors: always, auto (when writing to a terminal) or never
+	output             string                  // File to write the output to instead of stdout, if not empty
+	listSources        bool                    // List the sources instead of looking words up
+
+	// collapse reports whether a group is shown as just its heading, if not nil.
+	// It's used by interactive mode for :expand and :collapse.
+	collapse func(heading string) bool
+}
+
+// envPrefix is prepended to a flag's name to get the environment variable for it.
+const envPrefix = "GODICT_"
+
+// envName returns the environment variable that provides the default for a flag.
+// For example, "no-color" becomes "GODICT_NO_COLOR".
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// setFromEnv sets each flag in fs from its environment variable, if it's set.
+// It must be called before parsing, so that flags given on the command line win.
+func setFromEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if !ok || err != nil {
+			return
+		}
+		if setErr := f.Value.Set(v); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", v, envName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// splitList splits a comma separated list, trimming the spaces around each item
+// and leaving out empty ones.
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// wrapIndentColumn is the --wrap-indent that aligns wrapped lines under the definition text.
+const wrapIndentColumn = -1
+
+// parseWrapIndent returns the number of spaces to indent wrapped lines with for a --wrap-indent
+// value, which is wrapIndentColumn for "column" and zero for "none".
+func parseWrapIndent(s string) (int, error) {
+	switch s {
+	case "column":
+		return wrapIndentColumn, nil
+	case "none":
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid --wrap-indent %q, must be column, none, or a number of spaces", s)
+	}
+	return n, nil
+}
+
+// countTrue returns how many of the bools are true.
+func countTrue(bs ...bool) int {
+	n := 0
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// parseFlags parses the command line arguments into options.
+// The remaining arguments, which are the words to lookup, are returned as well.
+// Usage and errors are written to output.
+func parseFlags(args []string, output io.Writer) (*options, []string, error) {
+	opts := &options{headers: make(headerList)}
+	var dictPriority, definitionsFrom, locale, lang, sourceOrder, wrapIndent string
+	fs := flag.NewFlagSet("go-dict", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-dict [flags] [word...]")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), "\nEvery flag can also be set with an environment variable, like "+envName("no-color")+"=true.")
+	}
+	fs.BoolVar(&opts.dedupWithinDict, "dedup-within-dictionary", false, "remove exact duplicate definitions within each dictionary")
+	fs.BoolVar(&opts.sortByCount, "sort-dictionaries-by-count", false, "show dictionaries with the most definitions first")
+	fs.StringVar(&definitionsFrom, "definitions-from", "", "comma separated `list` of dictionaries to only show definitions from")
+	fs.BoolVar(&opts.primary, "primary", false, "only show the dictionary with the most definitions")
+	fs.BoolVar(&opts.noWordType, "no-wordtype", false, "hide the word type (part of speech) of each definition")
+	fs.BoolVar(&opts.examples, "examples", false, "show example sentences under each definition")
+	fs.BoolVar(&opts.exampleCounts, "example-counts", false, "show how many examples each definition has, without showing them")
+	fs.IntVar(&opts.truncateExamples, "truncate-examples", 0, "cut examples longer than `N` characters short, with an ellipsis (default no limit)")
+	fs.BoolVar(&opts.onlyWithExamples, "definitions-only-with-examples", false, "only show definitions that have at least one example")
+	fs.IntVar(&opts.head, "head", 0, "show only the first `N` definitions of each dictionary")
+	fs.IntVar(&opts.tail, "tail", 0, "show only the last `N` definitions of each dictionary, the most obscure ones")
+	fs.IntVar(&opts.limitDicts, "limit-dictionaries", 0, "show only the first `N` dictionaries, in the order they're shown in")
+	fs.IntVar(&opts.maxTotal, "max-total", 0, "show at most `N` definitions in total, taken in turns from each dictionary")
+	fs.BoolVar(&opts.explain, "explain", false, "show where on the page each definition was scraped from")
+	fs.BoolVar(&opts.agreement, "agreement", false, "when using more than one source, find which of them define the word the same way, to show with --explain and --json")
+	fs.StringVar(&opts.sortWords, "sort-words", "input", "order to output words in: input (as given) or alpha (alphabetically)")
+	fs.StringVar(&opts.groupBy, "group-by", "dictionary", "how to group definitions: dictionary, pos (part of speech), or none")
+	fs.StringVar(&dictPriority, "dict-priority", "", "comma separated `list` of dictionaries to show first, in order, like \"wiktionary,century\"")
+	fs.BoolVar(&opts.gloss, "show-rank-zero-only", false, "show just the top definition of the highest priority dictionary, on one line")
+	fs.IntVar(&opts.summaryWidth, "summary-width", 0, "with --show-rank-zero-only, cut definitions off at `N` characters (default what fits in the terminal)")
+	fs.BoolVar(&opts.statsByPOS, "stats-by-pos", false, "show how many definitions each word has of each part of speech, instead of the definitions")
+	fs.BoolVar(&opts.check, "check", false, "spell check: print whether each word is ok or unknown, instead of definitions")
+	fs.BoolVar(&opts.thesaurus, "thesaurus", false, "show synonyms, antonyms and other related words instead of definitions")
+	fs.BoolVar(&opts.compact, "compact", false, "don't show the dictionary name when only one dictionary has definitions")
+	fs.IntVar(&opts.best, "best", 0, "show only the `N` best definitions across all dictionaries, without grouping")
+	fs.BoolVar(&opts.json, "json", false, "output JSON, one object per word per line")
+	fs.BoolVar(&opts.jsonMinimal, "json-minimal", false, "output JSON with just the word and the text of its definitions")
+	fs.BoolVar(&opts.csv, "csv", false, "output CSV, one row per definition")
+	fs.BoolVar(&opts.attribution, "attribution", false, "show the credit and license text of each dictionary under its definitions")
+	fs.BoolVar(&opts.trimDictNames, "trim-dictionary-names", false, "abbreviate long dictionary names, like \"American Heritage\"")
+	fs.StringVar(&opts.configPath, "config", defaultConfigPath(), "path to the JSON config file")
+	fs.BoolVar(&opts.listSources, "list-sources", false, "list the sources that can be used with --source, and whether they're ready to use")
+	fs.StringVar(&opts.sources, "source", "wordnik", "comma separated `list` of sources to look words up in, which are queried concurrently")
+	fs.StringVar(&sourceOrder, "source-order", "", "comma separated `list` of sources to try one at a time, using the first that has the word")
+	fs.BoolVar(&opts.stopOnNetworkError, "stop-on-network-error", false, "with --source-order, report a network error instead of trying the next source")
+	fs.StringVar(&opts.acceptLanguage, "accept-language", "", "send the Accept-Language header with the `languages` to wordnik, like \"en-GB,en;q=0.8\"")
+	fs.Var(opts.headers, "header", "add a header to every request, like \"Accept-Language: fr\", can be repeated")
+	opts.maxResponseSize = defaultMaxResponseSize
+	fs.Var(&opts.maxResponseSize, "max-response-size", "the biggest response `size` to read, like 512KB, or 0 for no limit")
+	fs.StringVar(&opts.caCert, "ca-cert", "", "trust the CA certificates in the PEM file at `path`, as well as the system ones")
+	fs.BoolVar(&opts.insecure, "insecure-skip-verify", false, "don't verify HTTPS certificates, only for testing")
+	fs.BoolVar(&opts.failFast, "fail-fast", false, "stop at the first word that can't be looked up")
+	fs.BoolVar(&opts.networkFatal, "only-network-errors-fatal", false, "stop at the first network error, but keep going after words that aren't found")
+	fs.BoolVar(&opts.offline, "offline", false, "only use the small dictionary built into go-dict, without the network")
+	fs.BoolVar(&opts.interactive, "interactive", false, "look up words typed at a prompt, with tab completion")
+	fs.BoolVar(&opts.watchClipboard, "watch-clipboard", false, "run until stopped, defining every single word copied to the clipboard")
+	fs.DurationVar(&opts.clipboardInterval, "clipboard-interval", time.Second, "how often --watch-clipboard checks the clipboard")
+	fs.BoolVar(&opts.random, "random", false, "look up a random word")
+	fs.BoolVar(&opts.wotd, "wotd", false, "look up the word of the day")
+	fs.StringVar(&opts.reverse, "reverse", "", "find words from a `description` of their meaning, like \"fear of heights\"")
+	fs.IntVar(&opts.reverseLimit, "reverse-limit", 10, "with --reverse, find at most `N` words")
+	fs.BoolVar(&opts.defineCandidates, "define-candidates", false, "with --reverse, look up the words found instead of just listing them")
+	fs.DurationVar(&opts.watch, "watch", 0, "with --random or --wotd, run until stopped, showing a new word every `interval`")
+	fs.BoolVar(&opts.copy, "copy", false, "copy the definitions to the clipboard as plain text, as well as printing them")
+	fs.BoolVar(&opts.notify, "notify", false, "with --watch-clipboard, show the top definition as a desktop notification")
+	fs.StringVar(&opts.file, "file", "", "read words to look up from `path`, one per line, or stdin if it's -")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print the requests looking up the words would make to stderr, without making them")
+	fs.BoolVar(&opts.prefetch, "prefetch", false, "look words up and cache them without printing them, to use later offline")
+	fs.BoolVar(&opts.noCache, "no-cache", false, "don't read or write the cache of looked up words")
+	fs.DurationVar(&opts.cacheTTL, "cache-ttl", defaultCacheTTL, "how long to use cached lookups for")
+	fs.DurationVar(&opts.negativeCacheTTL, "negative-cache-ttl", defaultNegativeCacheTTL, "how long to remember that words weren't found")
+	fs.IntVar(&opts.concurrency, "concurrency", 8, "look up at most `N` words at the same time")
+	fs.DurationVar(&opts.jitter, "jitter", 0, "wait a random time up to this long before looking up each word, like 200ms, to spread out requests")
+	fs.BoolVar(&opts.debug, "debug", false, "print debug messages to stderr")
+	fs.BoolVar(&opts.lemmatize, "lemmatize", false, "when a word isn't found, look up its base form instead, like mouse for mice")
+	fs.BoolVar(&opts.caseSensitive, "case-sensitive", false, "look words up with their case as given, instead of lowercasing them")
+	fs.BoolVar(&opts.anki, "anki", false, "output tab separated flashcards for importing into Anki")
+	fs.StringVar(&opts.glossary, "glossary", "", "output an alphabetically sorted glossary of the words, in the `format` markdown or text")
+	fs.IntVar(&opts.glossaryDefs, "glossary-defs", 1, "with --glossary, show the `N` best definitions of each word")
+	fs.StringVar(&lang, "lang", "", "drop definitions detected as being in a language other than the `code`, like \"en\"")
+	fs.Float64Var(&opts.langConfidence, "lang-confidence", defaultLangConfidence, "how confident, from 0 to 1, the detection has to be to drop a definition with --lang")
+	fs.StringVar(&locale, "locale", systemLocale(), "the `locale` to sort alphabetically in, like \"fr-CA\"")
+	fs.IntVar(&opts.width, "width", 0, "wrap definitions to `N` characters wide (default the terminal width, or no wrapping when piped)")
+	fs.StringVar(&wrapIndent, "wrap-indent", "column", "how to indent wrapped lines of definitions: column (under the text), a number of spaces, or none")
+	fs.BoolVar(&opts.pager, "pager", false, "show output that doesn't fit in the terminal in $PAGER, or less")
+	fs.IntVar(&opts.pagerThreshold, "pager-threshold", 0, "with --pager, only use the pager for output longer than `N` lines (default the terminal height)")
+	fs.IntVar(&opts.columns, "columns", 1, "show short definitions in a grid of up to `N` columns that fit in the terminal")
+	fs.StringVar(&opts.separator, "separator", "blank", "what's between words: blank (a blank line), rule (a line across the terminal), or none")
+	fs.BoolVar(&opts.bannerCount, "banner-count", false, "show how many definitions were found next to each word")
+	fs.BoolVar(&opts.frequency, "frequency", false, "show how common each word is as stars, when the source knows")
+	fs.BoolVar(&opts.themePreview, "theme-preview", false, "show a sample word in each of the preset themes, to pick one for the config file")
+	fs.BoolVar(&opts.noColor, "no-color", false, "disable colored output, the same as --color never")
+	fs.StringVar(&opts.output, "output", "", "write the output to the file at `path` instead of stdout, without colors unless --color always is given")
+	fs.StringVar(&opts.color, "color", "auto", "when to use colors: always, auto (only when writing to a terminal), or never")
+	fs.DurationVar(&opts.timeout, "timeout", 0, "give up on a word after this long, like 10s (default no timeout)")
+	if err := setFromEnv(fs); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sourceOrder != "" {
+		sourceSet := false
+		fs.Visit(func(f *flag.Flag) {
+			sourceSet = sourceSet || f.Name == "source"
+		})
+		if sourceSet {
+			err := errors.New("only one of --source and --source-order can be used")
+			fmt.Fprintln(fs.Output(), err)
+			return nil, nil, err
+		}
+		opts.sources = sourceOrder
+		opts.fallback = true
+	} else if opts.stopOnNetworkError {
+		err := errors.New("--stop-on-network-error needs --source-order")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.offline {
+		opts.sources = "offline"
+	}
+	opts.dictPriority = splitList(dictPriority)
+	opts.definitionsFrom = splitList(definitionsFrom)
+	opts.wrapIndent, err = parseWrapIndent(wrapIndent)
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	opts.locale, err = parseLocale(locale)
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	opts.lang, err = parseLang(lang)
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if _, _, err := language.ParseAcceptLanguage(opts.acceptLanguage); opts.acceptLanguage != "" && err != nil {
+		err := fmt.Errorf("invalid --accept-language %q, must be language tags like \"en-GB,en;q=0.8\"", opts.acceptLanguage)
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.langConfidence < 0 || opts.langConfidence > 1 {
+		err := errors.New("--lang-confidence must be between 0 and 1")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.sortWords != "input" && opts.sortWords != "alpha" {
+		err := fmt.Errorf("unknown word order %q for --sort-words, must be input or alpha", opts.sortWords)
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.glossary != "" && opts.glossary != "markdown" && opts.glossary != "text" {
+		err := fmt.Errorf("unknown glossary format %q for --glossary, must be markdown or text", opts.glossary)
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.glossaryDefs < 1 {
+		err := errors.New("--glossary-defs must be at least 1")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.glossary != "" {
+		// A glossary is always in alphabetical order
+		opts.sortWords = "alpha"
+	}
+	switch opts.color {
+	case "always", "auto":
+	case "never":
+		opts.noColor = true
+	default:
+		err := fmt.Errorf("unknown value %q for --color, must be always, auto, or never", opts.color)
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.noColor && opts.color == "always" {
+		err := errors.New("--no-color can't be used with --color always")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.separator != "blank" && opts.separator != "rule" && opts.separator != "none" {
+		err := fmt.Errorf("unknown separator %q for --separator, must be blank, rule, or none", opts.separator)
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	opts.grouper, err = getGrouper(opts.groupBy)
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if countTrue(opts.json, opts.jsonMinimal, opts.csv, opts.anki, opts.glossary != "") > 1 {
+		err := errors.New("only one of --json, --json-minimal, --csv, --anki, and --glossary can be used")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.interactive && (opts.prefetch || opts.check) {
+		err := errors.New("--interactive can't be used with --prefetch or --check")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.watchClipboard && (opts.interactive || opts.prefetch || opts.check) {
+		err := errors.New("--watch-clipboard can't be used with --interactive, --prefetch or --check")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.glossary != "" && (opts.interactive || opts.watchClipboard || opts.prefetch || opts.check || opts.thesaurus) {
+		err := errors.New("--glossary can't be used with --interactive, --watch-clipboard, --prefetch, --check or --thesaurus")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.copy && (opts.watchClipboard || opts.interactive || opts.prefetch) {
+		err := errors.New("--copy can't be used with --watch-clipboard, --interactive or --prefetch")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.random && opts.wotd {
+		err := errors.New("only one of --random and --wotd can be used")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if (opts.random || opts.wotd) && (opts.interactive || opts.watchClipboard || opts.prefetch || opts.check) {
+		err := errors.New("--random and --wotd can't be used with --interactive, --watch-clipboard, --prefetch or --check")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.reverse != "" && (opts.random || opts.wotd || opts.interactive || opts.watchClipboard || opts.prefetch || opts.check) {
+		err := errors.New("--reverse can't be used with --random, --wotd, --interactive, --watch-clipboard, --prefetch or --check")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.reverseLimit < 1 {
+		err := errors.New("--reverse-limit must be at least 1")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.defineCandidates && opts.reverse == "" {
+		err := errors.New("--define-candidates needs --reverse")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.watch < 0 {
+		err := errors.New("--watch must be positive")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.watch > 0 && !opts.random && !opts.wotd {
+		err := errors.New("--watch needs --random or --wotd")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.clipboardInterval <= 0 {
+		err := errors.New("--clipboard-interval must be positive")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.dryRun && (opts.random || opts.wotd || opts.reverse != "" || opts.interactive || opts.watchClipboard || opts.prefetch || opts.check) {
+		err := errors.New("--dry-run can't be used with --random, --wotd, --reverse, --interactive, --watch-clipboard, --prefetch or --check")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.prefetch && opts.noCache {
+		err := errors.New("--prefetch can't be used with --no-cache")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.concurrency < 1 {
+		err := errors.New("--concurrency must be at least 1")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.jitter < 0 {
+		err := errors.New("--jitter can't be negative")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.summaryWidth < 0 {
+		err := errors.New("--summary-width can't be negative")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.columns < 1 {
+		err := errors.New("--columns must be at least 1")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.pagerThreshold < 0 {
+		err := errors.New("--pager-threshold can't be negative")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.pager && (opts.interactive || opts.watchClipboard || opts.prefetch) {
+		err := errors.New("--pager can't be used with --interactive, --watch-clipboard or --prefetch")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.truncateExamples < 0 {
+		err := errors.New("--truncate-examples can't be negative")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.limitDicts < 0 {
+		err := errors.New("--limit-dictionaries can't be negative")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.maxTotal < 0 {
+		err := errors.New("--max-total can't be negative")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.best < 0 {
+		err := errors.New("--best can't be negative")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.head < 0 || opts.tail < 0 {
+		err := errors.New("--head and --tail can't be negative")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	if opts.head > 0 && opts.tail > 0 {
+		err := errors.New("--head and --tail can't be used together")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, nil, err
+	}
+	return opts, fs.Args(), nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// freedictURL is the default URL of the Free Dictionary API's English entries, the word is added to the end.
+const freedictURL = "https://api.dictionaryapi.dev/api/v2/entries/en/"
+
+// freedictDict is the dictionary name of definitions from the Free Dictionary API,
+// which doesn't say which dictionary they come from.
+const freedictDict = "Free Dictionary"
+
+// freedictEntry is an entry as returned by the Free Dictionary API. A word with
+// more than one etymology, like "bass", has an entry for each.
+type freedictEntry struct {
+	Word      string `json:"word"`
+	Phonetic  string `json:"phonetic"`
+	Phonetics []struct {
+		Text string `json:"text"`
+	} `json:"phonetics"`
+	Meanings []struct {
+		PartOfSpeech string `json:"partOfSpeech"`
+		Definitions  []struct {
+			Definition string   `json:"definition"`
+			Example    string   `json:"example"`
+			Synonyms   []string `json:"synonyms"`
+			Antonyms   []string `json:"antonyms"`
+		} `json:"definitions"`
+		Synonyms []string `json:"synonyms"`
+		Antonyms []string `json:"antonyms"`
+	} `json:"meanings"`
+	License struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"license"`
+	SourceURLs []string `json:"sourceUrls"`
+}
+
+// pronunciation returns the IPA pronunciation of the entry, if it has one.
+func (fe *freedictEntry) pronunciation() string {
+	if fe.Phonetic != "" {
+		return fe.Phonetic
+	}
+	for _, p := range fe.Phonetics {
+		if p.Text != "" {
+			return p.Text
+		}
+	}
+	return ""
+}
+
+// freedictLookup returns an entry for the word using the Free Dictionary API.
+// base is the URL of its entries, usually freedictURL.
+func freedictLookup(ctx context.Context, base, w string, client *http.Client) (*entry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", base+url.PathEscape(w), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(ctx, client, req)
+	if errors.Is(err, ErrRateLimited) || (err != nil && ctx.Err() != nil) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w to the Free Dictionary API: %s", ErrNetwork, connectProblem(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// The body is a "No Definitions Found" message
+		return nil, ErrWordNotFound
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("the Free Dictionary API returned %s", resp.Status)
+	}
+	var fes []freedictEntry
+	if err := json.NewDecoder(resp.Body).Decode(&fes); err != nil {
+		return nil, parseError(err, "malformed JSON from the Free Dictionary API")
+	}
+	if len(fes) == 0 {
+		return nil, ErrWordNotFound
+	}
+
+	e := &entry{word: w, url: base + url.PathEscape(w), defs: make([]ctxDefinition, 0)}
+	relIndex := make(map[string]int)
+	seen := make(map[string]bool) // Related words are repeated for each meaning they fit
+	addRelated := func(kind, wordType string, words []string) {
+		key := kind + "\x00" + wordType
+		for _, rw := range words {
+			if seen[key+"\x00"+rw] {
+				continue
+			}
+			seen[key+"\x00"+rw] = true
+			i, ok := relIndex[key]
+			if !ok {
+				i = len(e.related)
+				relIndex[key] = i
+				e.related = append(e.related, relation{kind: kind, wordType: wordType})
+			}
+			e.related[i].words = append(e.related[i].words, rw)
+		}
+	}
+	var rank int
+	for i, fe := range fes {
+		homograph := 0
+		if len(fes) > 1 {
+			homograph = i + 1
+		}
+		if pron := fe.pronunciation(); pron != "" {
+			if e.pronunciations == nil {
+				e.pronunciations = make(map[int]string)
+			}
+			e.pronunciations[homograph] = pron
+		}
+		src := e.url
+		if len(fe.SourceURLs) > 0 {
+			src = fe.SourceURLs[0]
+		}
+		attribution := fe.License.Name
+		if fe.License.URL != "" {
+			attribution = strings.TrimSpace(attribution + " " + fe.License.URL)
+		}
+		if homograph > 0 {
+			// Ranks restart for each homograph, since they're shown as separate groups
+			rank = 0
+		}
+		for j, m := range fe.Meanings {
+			addRelated("synonyms", m.PartOfSpeech, m.Synonyms)
+			addRelated("antonyms", m.PartOfSpeech, m.Antonyms)
+			for k, d := range m.Definitions {
+				addRelated("synonyms", m.PartOfSpeech, d.Synonyms)
+				addRelated("antonyms", m.PartOfSpeech, d.Antonyms)
+				text := strings.TrimSpace(cleanText(d.Definition))
+				if text == "" {
+					continue
+				}
+				var examples []string
+				if ex := strings.TrimSpace(cleanText(d.Example)); ex != "" {
+					examples = []string{ex}
+				}
+				e.defs = append(e.defs, ctxDefinition{
+					dict:        freedictDict,
+					rank:        rank,
+					sourceURL:   src,
+					homograph:   homograph,
+					attribution: attribution,
+					def: definition{
+						wordType: m.PartOfSpeech,
+						text:     text,
+						examples: examples,
+						origin:   &origin{heading: i, list: j, rank: k},
+					},
+				})
+				rank++
+			}
+		}
+	}
+	e.formOf = findFormOf(w, e.defs)
+	return e, nil
+}
+
+// freedict is the Source that uses the Free Dictionary API at dictionaryapi.dev,
+// which doesn't need an API key.
+type freedict struct {
+	client  *http.Client
+	baseURL string
+}
+
+func (fd *freedict) Name() string {
+	return "freedict"
+}
+
+func (fd *freedict) Lookup(ctx context.Context, w string) (*entry, error) {
+	return freedictLookup(ctx, fd.baseURL, w, fd.client)
+}
+
+func (fd *freedict) Thesaurus(ctx context.Context, w string) (*entry, error) {
+	return fd.Lookup(ctx, w)
+}
+
+func (fd *freedict) PlannedRequests(w string) []string {
+	return []string{fd.baseURL + url.PathEscape(w)}
+}
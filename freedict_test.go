@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFreedictLongList(t *testing.T) {
+	defs := make([]map[string]string, 300)
+	for i := range defs {
+		defs[i] = map[string]string{"definition": fmt.Sprintf("Sense %d.", i)}
+	}
+	body, err := json.Marshal([]map[string]interface{}{{
+		"word":     "set",
+		"meanings": []map[string]interface{}{{"partOfSpeech": "noun", "definitions": defs}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer ts.Close()
+	e, err := freedictLookup(context.Background(), ts.URL+"/", "set", ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.defs) != 300 {
+		t.Fatalf("got %d definitions, want 300", len(e.defs))
+	}
+	// Ranks don't wrap around after 255
+	for i, cD := range e.defs {
+		if cD.rank != i {
+			t.Fatalf("definition %d has rank %d", i, cD.rank)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strings"
+)
+
+// maxStars is how many stars the most common words get with --frequency.
+const maxStars = 5
+
+// frequencyStars returns how common a word is as stars, like "★★★☆☆", from how
+// many times it's used. Each star is ten times as many uses as the last, so a word
+// used once gets one star, and one used 10,000 times or more gets all five.
+func frequencyStars(n int) string {
+	stars := int(math.Log10(float64(n))) + 1
+	if stars > maxStars {
+		stars = maxStars
+	}
+	return strings.Repeat("★", stars) + strings.Repeat("☆", maxStars-stars)
+}
+
+// lookupFrequency returns how many times the word is used, from the first source
+// that knows, or 0 if none of them do.
+func lookupFrequency(ctx context.Context, w string, srcs []Source) int {
+	for _, src := range srcs {
+		fs, ok := src.(frequencySource)
+		if !ok {
+			continue
+		}
+		n, err := fs.Frequency(ctx, w)
+		if err != nil {
+			debugLog.Printf("%s: frequency of %q failed: %v", src.Name(), w, err)
+			continue
+		}
+		return n
+	}
+	return 0
+}
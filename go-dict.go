@@ -1,37 +1,186 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
-	"github.com/PuerkitoBio/goquery"
 	"gopkg.in/gookit/color.v1"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
 	"net/http"
 	"os"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"text/tabwriter"
+	"time"
+	"unicode/utf8"
 )
 
 // definition is a struct for storing simple word definitions.
 type definition struct {
-	wordType string // noun, verb, interjection, intransitive verb, etc
-	text     string // The actual definition itself
+	wordType string   // noun, verb, interjection, intransitive verb, etc
+	label    string   // Usage labels like archaic or slang, separated by commas, if there are any
+	text     string   // The actual definition itself
+	examples []string // Example sentences using the word with this meaning
+	origin   *origin  // Where the definition was scraped from, or nil if the source doesn't say
+	agreed   []string // The sources that define the word the same way, with --agreement
+}
+
+// origin records where on the page a definition was scraped from, for --explain.
+type origin struct {
+	heading int // Index of the dictionary heading, or -1 if it doesn't have one
+	list    int // Index of the definition list
+	rank    int // Index of the definition within its list
+}
+
+func (o origin) String() string {
+	return fmt.Sprintf("[heading %d, list %d, rank %d]", o.heading, o.list, o.rank)
 }
 
 // ctxDefinition includes additional info about a definition.
 type ctxDefinition struct {
-	dict string // The dictionary the definition comes from
-	rank uint8  // Where this definition is compared to the others
-	def  definition
+	dict      string // The dictionary the definition comes from
+	rank      int    // Where this definition is compared to the others
+	sourceURL string // Where the definition can be found, for attribution
+	homograph int    // Which homograph of the word it defines, from 1, or 0 if the dictionary has just one
+	// attribution is the credit and license text the source gives for the dictionary, if any
+	attribution string
+	def         definition
+}
+
+// groupName returns the name of the group the definition is displayed in, which is
+// its dictionary, numbered when the dictionary splits the word into homographs.
+func (cD *ctxDefinition) groupName() string {
+	if cD.homograph == 0 {
+		return cD.dict
+	}
+	return fmt.Sprintf("%s (%d)", cD.dict, cD.homograph)
+}
+
+// relation is a group of words related to the looked up word in the same way.
+type relation struct {
+	kind string // synonyms, antonyms, etc
+	// wordType is the word type of the meaning the words are related to, like "noun",
+	// if the source gives one
+	wordType string
+	words    []string
+}
+
+// entry holds everything that was looked up for a single word.
+type entry struct {
+	word    string // The word as it was looked up
+	url     string // The page the word was looked up on
+	formOf  string // The base word, if this word is only an inflected form of it
+	lemma   string // The base form that was looked up instead, with --lemmatize
+	defs    []ctxDefinition
+	related []relation
+	parsed  int // How many definitions there were before filterDefs, for --banner-count
+	// pronunciations maps homograph numbers to their pronunciation in IPA, if known.
+	// The pronunciation of the word as a whole is under 0.
+	pronunciations map[int]string
+	frequency      int // How many times the word is used in the source's corpus, 0 if unknown
+	// timing is how long looking the word up took, for --json with --debug, or nil
+	timing *lookupTiming
+}
+
+// lookupTiming is how long looking up a word took.
+type lookupTiming struct {
+	took     time.Duration
+	cacheHit bool // Whether every source had the word cached
+}
+
+// clone returns a copy of the entry that can be changed without changing e,
+// like by writeEntry.
+func (e *entry) clone() *entry {
+	c := *e
+	c.defs = make([]ctxDefinition, len(e.defs))
+	copy(c.defs, e.defs)
+	for i := range c.defs {
+		c.defs[i].def.examples = append([]string(nil), e.defs[i].def.examples...)
+	}
+	c.related = make([]relation, len(e.related))
+	for i, rel := range e.related {
+		c.related[i] = relation{kind: rel.kind, wordType: rel.wordType, words: append([]string(nil), rel.words...)}
+	}
+	if e.pronunciations != nil {
+		c.pronunciations = make(map[int]string, len(e.pronunciations))
+		for h, pron := range e.pronunciations {
+			c.pronunciations[h] = pron
+		}
+	}
+	return &c
+}
+
+// inflectionRe matches definitions like "Present participle of run." or "Plural form of mouse."
+var inflectionRe = regexp.MustCompile(`(?i)^(?:.+ )?(?:participle|plural|tense|form|comparative|superlative|person singular)(?: form)? of ([\p{L}'-]+)\.?$`)
+
+// findFormOf returns the base word if the definitions say w is an inflected form of it.
+// It returns an empty string if no definition is an inflection note, or if the
+// base word is w itself.
+func findFormOf(w string, cDs []ctxDefinition) string {
+	for _, cD := range cDs {
+		m := inflectionRe.FindStringSubmatch(cD.def.text)
+		if m != nil && !strings.EqualFold(m[1], w) {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// shortDictNames maps the long dictionary names wordnik uses to short ones.
+var shortDictNames = map[string]string{
+	"The American Heritage® Dictionary of the English Language, 5th Edition":  "American Heritage",
+	"The American Heritage® Dictionary of the English Language, 4th Edition":  "American Heritage",
+	"Wiktionary, Creative Commons Attribution/Share-Alike License":            "Wiktionary",
+	"The Century Dictionary and Cyclopedia":                                   "Century",
+	"The Century Dictionary":                                                  "Century",
+	"GNU version of the Collaborative International Dictionary of English":    "GNU Collaborative",
+	"The Collaborative International Dictionary of English":                   "GNU Collaborative",
+	"WordNet 3.0 Copyright 2006 by Princeton University. All rights reserved": "WordNet",
+}
+
+// dictNames returns the short dictionary names to use, combining shortDictNames
+// with the ones from the config, which take precedence.
+func dictNames(conf *config) map[string]string {
+	m := make(map[string]string, len(shortDictNames)+len(conf.DictionaryNames))
+	for long, short := range shortDictNames {
+		m[long] = short
+	}
+	for long, short := range conf.DictionaryNames {
+		m[long] = short
+	}
+	return m
+}
+
+// trimDictNames replaces the dictionary names of the entry's definitions with
+// their short names from names. Unknown names are left as they are.
+func trimDictNames(e *entry, names map[string]string) {
+	for i := range e.defs {
+		if short, ok := names[e.defs[i].dict]; ok {
+			e.defs[i].dict = short
+		}
+	}
 }
 
 // byDictionary sorts ctxDefintions by rank and dictionary.
-// Returns a map with dictionary names as keys, and definition slices as values
-func byDictionary(cDs []ctxDefinition) map[string][]definition {
+// Returns a map with dictionary names as keys, and definition slices as values.
+// Homographs are kept apart, under their numbered group names.
+// If dedup is true, definitions with the same text as an earlier (lower rank)
+// one in the same dictionary are dropped.
+func byDictionary(cDs []ctxDefinition, dedup bool) map[string][]definition {
 	pre := make(map[string][]ctxDefinition) // Used for ranking, not returned
 	// Add all the defintions to the map
 	for _, cD := range cDs {
-		pre[cD.dict] = append(pre[cD.dict], cD)
+		pre[cD.groupName()] = append(pre[cD.groupName()], cD)
 	}
 	// Sort by rank
 	for k := range pre {
@@ -42,130 +191,1325 @@ func byDictionary(cDs []ctxDefinition) map[string][]definition {
 	// Convert to hold definitions only, not context
 	m := make(map[string][]definition)
 	for dict, cDs := range pre {
+		seen := make(map[string]bool)
 		for _, cD := range cDs {
+			if dedup {
+				if seen[cD.def.text] {
+					continue
+				}
+				seen[cD.def.text] = true
+			}
 			m[dict] = append(m[dict], cD.def)
 		}
 	}
 	return m
 }
 
+// dictMatches reports whether the dictionary name dict is the one name refers to.
+// The name can be any part of the dictionary's name, ignoring case, like "wiktionary".
+func dictMatches(dict, name string) bool {
+	return strings.Contains(strings.ToLower(dict), strings.ToLower(name))
+}
+
+// knownDict reports whether name refers to any of the dictionaries go-dict knows
+// the names of, in full or shortened by names.
+func knownDict(name string, names map[string]string) bool {
+	known := []string{bundledDict, freedictDict, wordnikUnknownDict}
+	for _, dict := range wordnikAPIDicts {
+		known = append(known, dict)
+	}
+	for long, short := range names {
+		known = append(known, long, short)
+	}
+	for _, dict := range known {
+		if dictMatches(dict, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDictNames replaces the aliases in a list of dictionary names given to a
+// flag with what they stand for, from the dictionary_aliases in the config file.
+// Aliases are matched ignoring case. Names that aren't aliases have to be part of
+// the name of a dictionary go-dict knows, otherwise it's assumed to be an unknown
+// alias, and the error lists the ones there are.
+func resolveDictNames(flagName string, list []string, aliases, names map[string]string) ([]string, error) {
+	ret := make([]string, 0, len(list))
+	for _, name := range list {
+		resolved := ""
+		for alias, dict := range aliases {
+			if strings.EqualFold(alias, name) {
+				resolved = dict
+				break
+			}
+		}
+		if resolved == "" && !knownDict(name, names) {
+			if len(aliases) == 0 {
+				return nil, fmt.Errorf("unknown dictionary %q for --%s, and there are no dictionary_aliases in the config file", name, flagName)
+			}
+			known := make([]string, 0, len(aliases))
+			for alias := range aliases {
+				known = append(known, alias)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown dictionary %q for --%s, the dictionary_aliases are: %s", name, flagName, strings.Join(known, ", "))
+		}
+		if resolved == "" {
+			resolved = name
+		}
+		ret = append(ret, resolved)
+	}
+	return ret, nil
+}
+
+// fromDicts returns only the ctxDefinitions from dictionaries one of the names
+// refers to, for --definitions-from.
+func fromDicts(cDs []ctxDefinition, names []string) []ctxDefinition {
+	ret := make([]ctxDefinition, 0, len(cDs))
+	for _, cD := range cDs {
+		if priorityIndex(cD.dict, names) < len(names) {
+			ret = append(ret, cD)
+		}
+	}
+	return ret
+}
+
+// priorityIndex returns the position of the first name in priority that refers to dict.
+// If none of them do, len(priority) is returned, putting it after all the ones that do.
+func priorityIndex(dict string, priority []string) int {
+	for i, name := range priority {
+		if dictMatches(dict, name) {
+			return i
+		}
+	}
+	return len(priority)
+}
+
+// dictOrder returns the dictionary names of m in the order they should be displayed.
+// Dictionaries in the --dict-priority list come first, in that order. The rest are
+// sorted alphabetically in the --locale, or by descending definition count with --sort-dictionaries-by-count,
+// with ties broken alphabetically.
+func dictOrder(m map[string][]definition, opts *options) []string {
+	dicts := make([]string, 0, len(m))
+	for dict := range m {
+		dicts = append(dicts, dict)
+	}
+	col := newCollator(opts.locale)
+	sort.Slice(dicts, func(i, j int) bool {
+		pi, pj := priorityIndex(dicts[i], opts.dictPriority), priorityIndex(dicts[j], opts.dictPriority)
+		if pi != pj {
+			return pi < pj
+		}
+		if opts.sortByCount && len(m[dicts[i]]) != len(m[dicts[j]]) {
+			return len(m[dicts[i]]) > len(m[dicts[j]])
+		}
+		return collateLess(col, dicts[i], dicts[j])
+	})
+	return dicts
+}
+
+// pluralize returns n followed by the noun, in plural form unless n is one.
+// Plurals are made by adding an "s", which works for every noun go-dict counts.
+// For example, pluralize(1, "definition") is "1 definition" and pluralize(0, "definition")
+// is "0 definitions".
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+// renderIPA returns an IPA pronunciation with the stressed syllables in bold, if c is true.
+// A stressed syllable starts after a primary stress mark (ˈ) and ends at the next
+// syllable boundary. Without color the IPA is returned untouched.
+func renderIPA(ipa string, c bool) string {
+	if !c {
+		return ipa
+	}
+	var b, syl strings.Builder
+	stressed := false
+	bold := style("stress")
+	endSyllable := func() {
+		if syl.Len() > 0 {
+			b.WriteString(bold.Render(syl.String()))
+			syl.Reset()
+		}
+		stressed = false
+	}
+	for _, r := range ipa {
+		switch r {
+		case 'ˈ':
+			endSyllable()
+			b.WriteRune(r)
+			stressed = true
+			continue
+		case 'ˌ', '.', ' ', '/', '[', ']', '(', ')', ',':
+			endSyllable()
+		}
+		if stressed {
+			syl.WriteRune(r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	endSyllable()
+	return b.String()
+}
+
+// score returns how good a definition is compared to others, lower being better.
+// The score is the definition's rank within its dictionary, plus half a point for
+// every dictionary displayed before its own, plus a point for every 100 characters of text.
+// So earlier definitions from earlier dictionaries win, and shorter ones break near ties.
+func (cD *ctxDefinition) score(dictIndex int) float64 {
+	return float64(cD.rank) + 0.5*float64(dictIndex) + float64(len(cD.def.text))/100
+}
+
+// bestDefinitions returns the n best ctxDefinitions according to their score, best first.
+// dicts is the display order of the dictionaries, as returned by dictOrder.
+func bestDefinitions(cDs []ctxDefinition, dicts []string, n int) []ctxDefinition {
+	index := make(map[string]int)
+	for i, dict := range dicts {
+		index[dict] = i
+	}
+	best := make([]ctxDefinition, len(cDs))
+	copy(best, cDs)
+	sort.SliceStable(best, func(i, j int) bool {
+		return best[i].score(index[best[i].groupName()]) < best[j].score(index[best[j].groupName()])
+	})
+	if n < len(best) {
+		best = best[:n]
+	}
+	return best
+}
+
+// pprintBest pretty prints the best definitions as a single list, with the
+// dictionary each one came from at the end of the line.
+func pprintBest(out io.Writer, cDs []ctxDefinition, c bool, opts *options) {
+	dicts := dictOrder(byDictionary(cDs, opts.dedupWithinDict), opts)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	for _, cD := range bestDefinitions(cDs, dicts, opts.best) {
+		dict := cD.dict
+		if c {
+			dict = style("note").Render(dict)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", cD.def.render(c, opts.noWordType), dict)
+	}
+	fmt.Fprintln(w)
+}
+
+// glossDefinition returns the single definition that best sums up the word.
+// It's the first definition of the highest priority dictionary in priority that
+// has any, or the first definition scraped if none of them do. It returns nil
+// if there are no definitions.
+func glossDefinition(cDs []ctxDefinition, priority []string) *ctxDefinition {
+	if len(cDs) == 0 {
+		return nil
+	}
+	var best *ctxDefinition
+	bestIndex := len(priority)
+	for i := range cDs {
+		pi := priorityIndex(cDs[i].dict, priority)
+		if pi == len(priority) {
+			continue
+		}
+		if best == nil || pi < bestIndex || (pi == bestIndex && cDs[i].rank < best.rank) {
+			best = &cDs[i]
+			bestIndex = pi
+		}
+	}
+	if best == nil {
+		return &cDs[0]
+	}
+	return best
+}
+
+// pprintGloss prints the word and its gloss definition on a single line.
+// The definition is truncated to --summary-width, or to fit the rest of the
+// terminal's width by default.
+func pprintGloss(out io.Writer, e *entry, c bool, opts *options) {
+	word := e.word
+	if c {
+		word = style("word").Render(word)
+	}
+	cD := glossDefinition(e.defs, opts.dictPriority)
+	if cD == nil {
+		fmt.Fprintln(out, word+"  (no definitions)")
+		return
+	}
+	width := opts.summaryWidth
+	if width == 0 {
+		width = opts.termWidth - visibleWidth(word) - 2
+	}
+	fmt.Fprintln(out, word+"  "+truncateVisible(cD.def.text, width))
+}
+
+// pprintRelated pretty prints the related word groups, optionally with color.
+func pprintRelated(out io.Writer, rels []relation, c bool) {
+	if len(rels) == 0 {
+		fmt.Fprintln(out, "No related words found.")
+	}
+	for _, rel := range rels {
+		kind := capitalize(rel.kind)
+		if rel.wordType != "" {
+			kind += " (" + rel.wordType + ")"
+		}
+		if c {
+			kind = style("relation").Render(kind)
+		}
+		fmt.Fprintln(out, kind)
+		fmt.Fprintln(out, strings.Join(rel.words, ", "))
+	}
+	fmt.Fprintln(out)
+}
+
+// labelPrefix returns the usage label of the definition in parentheses, followed by
+// a space, to go before its text. It's empty if the definition has no label.
+func (d *definition) labelPrefix(c bool) string {
+	if d.label == "" {
+		return ""
+	}
+	l := "(" + d.label + ")"
+	if c {
+		l = style("label").Render(l)
+	}
+	return l + " "
+}
+
 // render returns a formatted definition, optionally with color.
-// This contains some opinionted color defaults, as opposed to renderOps
-func (d *definition) render(c bool) string {
+// This contains some opinionted color defaults, as opposed to renderOps.
+// If noType is true, only the text is returned, without the word type column.
+func (d *definition) render(c, noType bool) string {
+	if noType {
+		return d.labelPrefix(c) + d.text
+	}
 	if c {
-		return color.New(color.OpItalic).Render(d.wordType) + "\t" + d.text
+		return style("word_type").Render(d.wordType) + "\t" + d.labelPrefix(c) + d.text
 	}
-	return d.wordType + "\t" + d.text
+	return d.wordType + "\t" + d.labelPrefix(c) + d.text
 }
 
 // renderOps returns a formatted color definition, according to the provided styles.
-func (d *definition) renderOps(wordType, text color.Style) string {
-	return wordType.Render(d.wordType) + "\t\t" + text.Render(d.text)
+// If noType is true, only the text is returned, without the word type column.
+func (d *definition) renderOps(wordType, text color.Style, noType bool) string {
+	if noType {
+		return d.labelPrefix(true) + text.Render(d.text)
+	}
+	return wordType.Render(d.wordType) + "\t\t" + d.labelPrefix(true) + text.Render(d.text)
 }
 
-// pprintCtxDefs pretty prints multiple context definitions, optionally with color.
-func pprintCtxDefs(cDs []ctxDefinition, c bool) {
-	m := byDictionary(cDs)
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	//esc := string(tabwriter.Escape)
-	for dict, defs := range m {
+// pprintExamples prints the examples of a definition on their own lines, under the definition text.
+func pprintExamples(w io.Writer, d *definition, c bool, opts *options) {
+	indent := "\t  "
+	if opts.noWordType {
+		indent = "  "
+	}
+	for _, ex := range d.examples {
+		ex = truncateExample(ex, opts)
 		if c {
-			// Bracket dict name with escape characters so it's not part of the tabbing
-			fmt.Fprintln(w, color.New(color.BgGray).Render(dict))
-			// Print first definition differently
-			fmt.Fprintf(w, "%s\n", defs[0].renderOps(color.New(color.OpItalic, color.OpBold), color.New(color.Cyan)))
-			for _, def := range defs[1:] {
-				fmt.Fprintf(w, "%s\n", def.render(true))
+			ex = style("example").Render(ex)
+		}
+		fmt.Fprintln(w, indent+ex)
+	}
+}
+
+// truncateExample cuts an example down to --truncate-examples characters, if it's longer.
+func truncateExample(ex string, opts *options) string {
+	if opts.truncateExamples == 0 {
+		return ex
+	}
+	return truncateVisible(ex, opts.truncateExamples)
+}
+
+// primaryDefs returns only the ctxDefinitions from the dictionary that has the most,
+// for --primary. Ties go to whichever dictionary would be shown first, which is by
+// --dict-priority and then alphabetical.
+func primaryDefs(cDs []ctxDefinition, opts *options) []ctxDefinition {
+	m := make(map[string][]definition)
+	for _, cD := range cDs {
+		m[cD.dict] = append(m[cD.dict], cD.def)
+	}
+	primary := ""
+	for _, dict := range dictOrder(m, opts) {
+		if primary == "" || len(m[dict]) > len(m[primary]) {
+			primary = dict
+		}
+	}
+	ret := make([]ctxDefinition, 0, len(m[primary]))
+	for _, cD := range cDs {
+		if cD.dict == primary {
+			ret = append(ret, cD)
+		}
+	}
+	return ret
+}
+
+// withExamples returns only the ctxDefinitions that have at least one example.
+func withExamples(cDs []ctxDefinition) []ctxDefinition {
+	ret := make([]ctxDefinition, 0, len(cDs))
+	for _, cD := range cDs {
+		if len(cD.def.examples) > 0 {
+			ret = append(ret, cD)
+		}
+	}
+	return ret
+}
+
+// roundRobin returns at most n of the ctxDefinitions, taken in turns from each
+// dictionary in display order: the first definition of every dictionary, then
+// the second of every dictionary, and so on. So every dictionary gets a fair
+// share, and dictionaries with fewer definitions leave more room for the others.
+func roundRobin(cDs []ctxDefinition, n int, opts *options) []ctxDefinition {
+	if len(cDs) <= n {
+		return cDs
+	}
+	byDict := make(map[string][]ctxDefinition)
+	counts := make(map[string][]definition) // Only used for dictOrder
+	for _, cD := range cDs {
+		byDict[cD.groupName()] = append(byDict[cD.groupName()], cD)
+		counts[cD.groupName()] = append(counts[cD.groupName()], cD.def)
+	}
+	for k := range byDict {
+		sort.SliceStable(byDict[k], func(i, j int) bool {
+			return byDict[k][i].rank < byDict[k][j].rank
+		})
+	}
+	dicts := dictOrder(counts, opts)
+	ret := make([]ctxDefinition, 0, n)
+	for turn := 0; len(ret) < n; turn++ {
+		for _, dict := range dicts {
+			if turn < len(byDict[dict]) && len(ret) < n {
+				ret = append(ret, byDict[dict][turn])
 			}
-		} else {
-			fmt.Fprintf(w, dict+"\n")
-			for _, def := range defs {
-				fmt.Fprintf(w, "%s\n", def.render(false))
+		}
+	}
+	return ret
+}
+
+// filterDefs removes the definitions of an entry that the options say shouldn't be shown.
+// The number of definitions there were beforehand is kept in e.parsed.
+func filterDefs(e *entry, opts *options) {
+	e.parsed = len(e.defs)
+	if len(opts.definitionsFrom) > 0 {
+		e.defs = fromDicts(e.defs, opts.definitionsFrom)
+	}
+	if opts.onlyWithExamples {
+		e.defs = withExamples(e.defs)
+	}
+	if opts.lang != "" {
+		e.defs = inLangDefs(e.defs, opts.lang, opts.langConfidence)
+	}
+	if opts.primary {
+		e.defs = primaryDefs(e.defs, opts)
+	}
+	if opts.maxTotal > 0 {
+		e.defs = roundRobin(e.defs, opts.maxTotal, opts)
+	}
+}
+
+// headTail returns the definitions selected by --head or --tail, and how many were left out.
+func headTail(defs []definition, opts *options) ([]definition, int) {
+	if opts.head > 0 && opts.head < len(defs) {
+		return defs[:opts.head], len(defs) - opts.head
+	}
+	if opts.tail > 0 && opts.tail < len(defs) {
+		return defs[len(defs)-opts.tail:], len(defs) - opts.tail
+	}
+	return defs, 0
+}
+
+// minWrapWidth is the narrowest definition text is wrapped to, any narrower and it isn't wrapped at all.
+const minWrapWidth = 20
+
+// wrapPrefix returns what continuation lines of a wrapped definition start with,
+// and how wide that is, according to --wrap-indent. textCol is the column the
+// definition text starts at.
+func wrapPrefix(d *definition, ops, c bool, textCol int, opts *options) (string, int) {
+	switch {
+	case opts.wrapIndent > 0:
+		return strings.Repeat(" ", opts.wrapIndent), opts.wrapIndent
+	case opts.wrapIndent == 0 || opts.noWordType:
+		return "", 0
+	}
+	// The tabwriter counts escape sequences as part of a cell's width, so the
+	// word type is replaced by spaces in the same style to line up with it
+	blank := strings.Repeat(" ", utf8.RuneCountInString(d.wordType))
+	switch {
+	case ops:
+		return style("first_word_type").Render(blank) + "\t\t", textCol
+	case c:
+		return style("word_type").Render(blank) + "\t", textCol
+	}
+	return "\t", textCol
+}
+
+// typeColumn returns roughly the column the text of the definitions starts at,
+// after the tabwriter pads their word types. It errs on the side of being too big.
+func typeColumn(defs []definition, opts *options) int {
+	if opts.noWordType {
+		return 0
+	}
+	widest := 0
+	for i := range defs {
+		if n := utf8.RuneCountInString(defs[i].wordType); n > widest {
+			widest = n
+		}
+	}
+	return widest + 4
+}
+
+// pprintDef prints a single definition, along with anything the options say
+// should be shown with it. The first definition of a dictionary is highlighted
+// when using color. The text is wrapped to --width, with textCol being the
+// column it starts at.
+func pprintDef(w io.Writer, d *definition, first, c bool, opts *options, textCol int) {
+	ops := c && first
+	num := ""
+	if opts.number != nil {
+		num = strconv.Itoa(opts.number(d)) + ". "
+		textCol += len(num)
+	}
+	lines := []string{d.text}
+	prefix, indent := wrapPrefix(d, ops, c, textCol, opts)
+	// The label only takes up room on the first line
+	firstWidth := opts.width - textCol - visibleWidth(d.labelPrefix(false))
+	if firstWidth >= minWrapWidth && opts.width-indent >= minWrapWidth {
+		lines = wrapText(d.text, firstWidth, opts.width-indent)
+	}
+	shown := *d
+	shown.text = lines[0]
+	var line string
+	if ops {
+		line = shown.renderOps(style("first_word_type"), style("first_definition"), opts.noWordType)
+	} else {
+		line = shown.render(c, opts.noWordType)
+	}
+	line = num + line
+	if opts.explain {
+		if d.origin != nil {
+			ex := d.origin.String()
+			if c {
+				ex = style("note").Render(ex)
+			}
+			line += "  " + ex
+		}
+		if len(d.agreed) > 0 {
+			ag := "(defined alike by " + strings.Join(d.agreed, ", ") + ")"
+			if c {
+				ag = style("note").Render(ag)
+			}
+			line += "  " + ag
+		}
+	}
+	if opts.exampleCounts && !opts.examples && len(d.examples) > 0 {
+		count := "(" + pluralize(len(d.examples), "example") + ")"
+		if c {
+			count = style("note").Render(count)
+		}
+		line += "  " + count
+	}
+	fmt.Fprintln(w, line)
+	for _, cont := range lines[1:] {
+		if ops {
+			cont = style("first_definition").Render(cont)
+		}
+		fmt.Fprintln(w, prefix+cont)
+	}
+	if opts.examples {
+		pprintExamples(w, d, c, opts)
+	}
+}
+
+// gridCell returns a definition as it's shown in a cell of the --columns grid,
+// on a single line without tabs.
+func gridCell(d *definition, first, c bool, opts *options) string {
+	text := d.text
+	wT := d.wordType
+	if c && first {
+		text = style("first_definition").Render(text)
+		wT = style("first_word_type").Render(wT)
+	} else if c {
+		wT = style("word_type").Render(wT)
+	}
+	text = d.labelPrefix(c) + text
+	if opts.noWordType || d.wordType == "" {
+		return text
+	}
+	return wT + " " + text
+}
+
+// pprintGrid prints the definitions in up to --columns columns that fit in the
+// terminal width, going down each column in turn. It reports false without
+// printing anything if fewer than two columns would fit, so the definitions
+// are too long to be worth putting in a grid.
+func pprintGrid(w io.Writer, defs []definition, c bool, opts *options) bool {
+	cells := make([]string, len(defs))
+	widest := 0
+	for i := range defs {
+		cells[i] = gridCell(&defs[i], i == 0, c, opts)
+		if cw := visibleWidth(cells[i]); cw > widest {
+			widest = cw
+		}
+	}
+	const gap = 2
+	cols := (opts.termWidth + gap) / (widest + gap)
+	if cols > opts.columns {
+		cols = opts.columns
+	}
+	if cols > len(cells) {
+		cols = len(cells)
+	}
+	if cols < 2 {
+		return false
+	}
+	rows := (len(cells) + cols - 1) / cols
+	for row := 0; row < rows; row++ {
+		var line strings.Builder
+		for col := 0; col < cols; col++ {
+			i := col*rows + row
+			if i >= len(cells) {
+				break
+			}
+			if col > 0 {
+				line.WriteString(strings.Repeat(" ", gap))
+			}
+			line.WriteString(cells[i])
+			if next := (col+1)*rows + row; col < cols-1 && next < len(cells) {
+				line.WriteString(strings.Repeat(" ", widest-visibleWidth(cells[i])))
 			}
 		}
+		fmt.Fprintln(w, line.String())
+	}
+	return true
+}
+
+// pprintCtxDefs pretty prints the context definitions of an entry to out, optionally with color.
+// The definitions are grouped according to --group-by. Groups of homographs have
+// the homograph's pronunciation after the heading, if it's known.
+func pprintCtxDefs(out io.Writer, e *entry, c bool, opts *options) {
+	groups, moreGroups := limitGroups(opts.grouper(e.defs, opts), opts)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	// Deferred so whatever was written is still output if rendering stops early
+	defer w.Flush()
+	//esc := string(tabwriter.Escape)
+	header := !opts.compact || len(groups)+moreGroups > 1
+	prevCollapsed := false
+	for _, g := range groups {
+		defs, more := headTail(g.defs, opts)
+		collapsed := header && g.heading != "" && len(groups) > 1 && opts.collapse != nil && opts.collapse(g.heading)
+		if prevCollapsed && !collapsed {
+			fmt.Fprintln(w)
+		}
+		prevCollapsed = collapsed
+		if header && g.heading != "" {
+			heading := g.heading
+			if c {
+				heading = style("heading").Render(heading)
+			}
+			if pron := e.pronunciations[g.homograph]; g.homograph > 0 && pron != "" {
+				heading += "  " + renderIPA(pron, c)
+			}
+			if collapsed {
+				// Collapsed groups are a line each, so they can all be seen at once
+				note := "(" + pluralize(len(g.defs), "definition") + ")"
+				if c {
+					note = style("note").Render(note)
+				}
+				fmt.Fprintln(w, heading+"  "+note)
+				continue
+			}
+			fmt.Fprintln(w, heading)
+		}
+		grid := opts.columns > 1 && !opts.examples && !opts.exampleCounts && !opts.explain && opts.number == nil
+		if !grid || !pprintGrid(w, defs, c, opts) {
+			textCol := typeColumn(defs, opts)
+			for i := range defs {
+				// Print first definition differently
+				pprintDef(w, &defs[i], i == 0, c, opts, textCol)
+			}
+		}
+		if more > 0 {
+			note := "... " + pluralize(more, "more definition")
+			if c {
+				note = style("note").Render(note)
+			}
+			fmt.Fprintln(w, note)
+		}
+		if opts.attribution && g.attribution != "" {
+			attr := g.attribution
+			if c {
+				attr = style("note").Render(attr)
+			}
+			fmt.Fprintln(w, attr)
+		}
+		fmt.Fprintln(w)
+	}
+	if prevCollapsed {
+		fmt.Fprintln(w)
+	}
+	if moreGroups > 0 {
+		note := moreGroupsNote(moreGroups, opts)
+		if c {
+			note = style("note").Render(note)
+		}
+		fmt.Fprintln(w, note)
 		fmt.Fprintln(w)
 	}
-	w.Flush()
 }
 
-// wordnikLookup returns a slice of ctxDefinitions for the provided word.
-// Looks up words using wordnik.com
-func wordnikLookup(w string, client *http.Client) ([]ctxDefinition, error) {
-	req, err := http.NewRequest("GET", "https://www.wordnik.com/words/"+w, nil)
+// lockedWriter is an io.Writer that serializes writes to the underlying writer,
+// so that each call to Write is output as one unit.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// pprintEntry pretty prints everything about an entry, starting with a banner of the word.
+// The gloss and --stats-by-pos modes are the exceptions, which print just a single line per word.
+func pprintEntry(out io.Writer, e *entry, c bool, opts *options) {
+	if opts.gloss {
+		pprintGloss(out, e, c, opts)
+		return
+	}
+	if opts.statsByPOS {
+		pprintPOSStats(out, e, c, opts)
+		return
+	}
+	banner := e.word
+	if c {
+		banner = style("banner").Render(banner)
+	}
+	if pron := e.pronunciations[0]; pron != "" {
+		banner += "  " + renderIPA(pron, c)
+	}
+	if opts.bannerCount {
+		banner += " (" + pluralize(e.parsed, "definition") + ")"
+	}
+	if opts.frequency && e.frequency > 0 {
+		stars := frequencyStars(e.frequency)
+		if c {
+			stars = style("note").Render(stars)
+		}
+		banner += "  " + stars
+	}
+	fmt.Fprintln(out, banner)
+	if e.lemma != "" {
+		note := "Showing results for base form: " + e.lemma
+		if c {
+			note = style("form_of").Render(note)
+		}
+		fmt.Fprintln(out, note)
+	}
+	if e.formOf != "" {
+		note := e.word + " is a form of " + e.formOf
+		if c {
+			note = style("form_of").Render(note)
+		}
+		fmt.Fprintln(out, note)
+	}
+	if opts.thesaurus {
+		pprintRelated(out, e.related, c)
+	} else if opts.best > 0 {
+		pprintBest(out, e.defs, c, opts)
+	} else {
+		pprintCtxDefs(out, e, c, opts)
+	}
+}
+
+// lookupResult is the outcome of looking up a single word.
+type lookupResult struct {
+	word   string
+	e      *entry
+	err    error
+	cached bool // Whether it was already in the cache, for --prefetch
+}
+
+// writeErrorStatus returns the exit status for when writing the output failed.
+// If stdout was closed early, like when piping into head, the program exits
+// quietly with the status a process killed by SIGPIPE would have.
+func writeErrorStatus(stderr io.Writer, err error) int {
+	if errors.Is(err, syscall.EPIPE) {
+		return 128 + 13
+	}
+	fmt.Fprintln(stderr, "go-dict: writing output:", err)
+	return 1
+}
+
+// closeOutput closes the --output file, returning the exit status run should have
+// once it's closed, which is a failure if closing it failed and run succeeded.
+// Closing is when some errors writing a file are reported, so they can't be ignored.
+func closeOutput(f io.Closer, code int, stderr io.Writer) int {
+	if err := f.Close(); err != nil {
+		if status := writeErrorStatus(stderr, err); code == 0 {
+			return status
+		}
+	}
+	return code
+}
+
+// slowWordThreshold is how long a word's lookup can take before --debug mentions it.
+const slowWordThreshold = 5 * time.Second
+
+// debugLog prints debug messages, which are discarded unless --debug is used.
+var debugLog = log.New(ioutil.Discard, "debug: ", 0)
+
+// run is the whole program, taking the command line arguments and returning the exit status.
+// The exit statuses are:
+//   - 0 if every word was looked up
+//   - 1 if some words couldn't be looked up, but the rest were still output,
+//     or with --check if any words are unknown
+//   - 2 if the flags or config file are invalid
+//   - 3 if the run was stopped early because of a fatal error, see --fail-fast
+//     and --only-network-errors-fatal
+//   - 141 if stdout was closed before everything was written
+//
+// urls overrides the URLs of sources by name, it's nil except when testing.
+func run(args []string, urls map[string]string, stdout, stderr io.Writer) (code int) {
+	opts, words, err := parseFlags(args, stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			fmt.Fprintln(stderr, "go-dict:", err)
+			return 2
+		}
+		defer func() { code = closeOutput(f, code, stderr) }()
+		// Everything is written to the file as if it was stdout, so it isn't a terminal
+		stdout = f
+	}
+	if opts.color == "auto" && !isTerminal(stdout) {
+		opts.noColor = true
+	}
+	if opts.listSources {
+		if len(words) > 0 {
+			fmt.Fprintln(stderr, "go-dict: words can't be given with --list-sources")
+			return 2
+		}
+		listSources(stdout)
+		return 0
+	}
+	if opts.themePreview {
+		if len(words) > 0 {
+			fmt.Fprintln(stderr, "go-dict: words can't be given with --theme-preview")
+			return 2
+		}
+		opts.termWidth = terminalWidth(stdout)
+		previewThemes(stdout, opts)
+		return 0
+	}
+	warnFileArgs(words, stderr)
+	// Words from stdin are looked up as they're read, unless they have to be sorted first
+	stream := opts.file == "-" && opts.sortWords == "input" && !opts.interactive && !opts.watchClipboard &&
+		!opts.random && !opts.wotd && opts.reverse == "" && !opts.dryRun
+	if opts.file != "" && !stream {
+		fileWords, err := readWordFile(opts.file)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		words = append(words, fileWords...)
+	}
+	if (opts.random || opts.wotd) && len(words) > 0 {
+		fmt.Fprintln(stderr, "go-dict: words can't be given with --random or --wotd")
+		return 2
+	}
+	if opts.reverse != "" && len(words) > 0 {
+		fmt.Fprintln(stderr, "go-dict: words can't be given with --reverse, put the whole description in quotes")
+		return 2
+	}
+	if len(words) == 0 && !stream && !opts.interactive && !opts.watchClipboard && !opts.random && !opts.wotd && opts.reverse == "" {
+		fmt.Fprintln(stdout, "Provide a word to lookup.")
+		return 0
+	}
+	conf, err := loadConfig(opts.configPath)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	names := dictNames(conf)
+	opts.dictPriority, err = resolveDictNames("dict-priority", opts.dictPriority, conf.DictionaryAliases, names)
+	if err == nil {
+		opts.definitionsFrom, err = resolveDictNames("definitions-from", opts.definitionsFrom, conf.DictionaryAliases, names)
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/74.0.3729.169 Safari/537.36")
-	resp, err := client.Do(req)
 	if err != nil {
-		return nil, errors.New("couldn't connect to wordnik")
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	theme = defaultTheme
+	if conf.theme != nil {
+		theme = conf.theme
+	}
+	opts.termWidth = terminalWidth(stdout)
+	if opts.width == 0 && isTerminal(stdout) {
+		// Piped output isn't wrapped, so other tools get whole lines
+		opts.width = opts.termWidth
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return nil, errors.New("200 not returned, likely a non-word like '../test' was passed")
+	if opts.debug {
+		debugLog.SetOutput(stderr)
 	}
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	var transport http.RoundTripper = http.DefaultTransport
+	if opts.caCert != "" || opts.insecure {
+		t, err := tlsTransport(opts.caCert, opts.insecure)
+		if err != nil {
+			fmt.Fprintln(stderr, "go-dict:", err)
+			return 2
+		}
+		transport = t
+	}
+	if opts.insecure {
+		fmt.Fprintln(stderr, "go-dict: warning: --insecure-skip-verify is set, HTTPS certificates aren't checked so connections can be intercepted")
+	}
+	if opts.maxResponseSize > 0 {
+		transport = &limitTransport{max: int64(opts.maxResponseSize), base: transport}
+	}
+	client := &http.Client{Transport: transport}
+	if len(opts.headers) > 0 {
+		client.Transport = &headerTransport{headers: http.Header(opts.headers), base: transport}
+	}
+	srcs, err := newSources(opts.sources, client, urls, opts.acceptLanguage)
 	if err != nil {
-		return nil, errors.New("malformed HTML from wordnik")
-	}
-	ret := make([]ctxDefinition, 0)
-	s := doc.Find(".word-module.module-definitions#define .guts.active").First()
-	dicts := s.Find("h3")
-	lists := s.Find("ul")
-	// Go through each list of defs., then each def., and add them
-	lists.Each(func(i int, list *goquery.Selection) {
-		list.Find("li").Each(func(j int, def *goquery.Selection) {
-			// wordType
-			wT := def.Find("abbr").First().Text() + " " + def.Find("i").First().Text()
-			wT = strings.TrimSpace(wT)
-			// dictionary
-			d := dicts.Get(i).FirstChild.Data[5:]             // strip the "from " prefix
-			d = strings.ToUpper(string(d[0])) + string(d[1:]) // Capitalize first letter
-			if string(d[len(d)-1]) == "." {                   // Remove ending period
-				d = string(d[:len(d)-1])
-			}
-			// definition text - remove the wordType at the beginning of the definition
-			t := strings.TrimSpace(def.Text()[len(wT):])
-			t = strings.ToUpper(string(t[0])) + string(t[1:]) // Capitalize first letter
-			ret = append(ret, ctxDefinition{
-				dict: d,
-				rank: uint8(j),
-				def: definition{
-					wordType: wT,
-					text:     t,
-				},
-			})
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	var c *cache
+	if !opts.noCache {
+		c, err = newCache(opts.cacheTTL, opts.negativeCacheTTL)
+		if err != nil {
+			debugLog.Printf("not using the cache: %v", err)
+		}
+	}
+	if opts.prefetch && c == nil {
+		fmt.Fprintln(stderr, "go-dict: can't prefetch without a cache directory")
+		return 2
+	}
+	if opts.reverse != "" {
+		candidates, status := runReverse(srcs, opts, stdout, stderr)
+		if status != 0 || !opts.defineCandidates {
+			return status
+		}
+		words = candidates
+	}
+	if opts.random || opts.wotd {
+		return runFeed(srcs, c, names, opts, stdout, stderr)
+	}
+	if opts.watchClipboard {
+		return runClipboardWatch(srcs, c, names, opts, stdout, stderr)
+	}
+	if opts.interactive {
+		r := &repl{srcs: srcs, cache: c, names: names, opts: opts, stdout: stdout, stderr: stderr}
+		for _, w := range words {
+			r.lookup(w)
+		}
+		return r.run()
+	}
+	if opts.dryRun {
+		dryRun(words, srcs, c, opts, stderr)
+		return 0
+	}
+	ctx := context.Background()
+	if opts.sortWords == "alpha" {
+		// Sorting before looking up keeps each word streaming out as soon as it's ready
+		col := newCollator(opts.locale)
+		sort.SliceStable(words, func(i, j int) bool {
+			return collateLess(col, words[i], words[j])
 		})
-	})
-	return ret, nil
+	}
+	input := make(chan string)
+	var inputErr error // Set before input is closed
+	go func() {
+		defer close(input)
+		for _, w := range words {
+			input <- w
+		}
+		if stream {
+			inputErr = scanWords(os.Stdin, func(w string) { input <- w })
+		}
+	}()
+	results := lookupWords(ctx, input, srcs, c, opts)
+
+	if opts.prefetch {
+		total := len(words)
+		if stream {
+			total = 0
+		}
+		exitCode := prefetchReport(results, total, stdout, stderr)
+		if inputErr != nil {
+			fmt.Fprintln(stderr, "go-dict: reading stdin:", inputErr)
+			exitCode = 1
+		}
+		return exitCode
+	}
+
+	// Print the answer of each word, each one written all at once
+	out := &lockedWriter{w: stdout}
+	if opts.pager && isTerminal(stdout) {
+		// Everything has to be written first, to know whether it needs the pager
+		var paged bytes.Buffer
+		out.w = &paged
+		threshold := opts.pagerThreshold
+		if threshold == 0 {
+			threshold = terminalHeight(stdout)
+		}
+		defer func() {
+			if err := writePaged(paged.Bytes(), threshold, stdout); err != nil {
+				debugLog.Printf("couldn't write the output: %v", err)
+			}
+		}()
+	}
+	var copied strings.Builder // The output without colors, for --copy
+	if opts.csv {
+		if err := writeCSVHeader(io.MultiWriter(out, &copied)); err != nil {
+			return writeErrorStatus(stderr, err)
+		}
+	}
+	exitCode := 0
+	written := 0 // Entries written so far, for --separator
+	for result := range results {
+		var buf bytes.Buffer
+		r := <-result
+		if opts.check && (r.err == nil || errors.Is(r.err, ErrWordNotFound)) {
+			status := "ok"
+			if r.err != nil {
+				status = "unknown"
+				exitCode = 1
+			}
+			fmt.Fprintln(&buf, r.word+" "+status)
+			if _, err := out.Write(buf.Bytes()); err != nil {
+				return writeErrorStatus(stderr, err)
+			}
+			if opts.copy {
+				copied.Write(buf.Bytes())
+			}
+			continue
+		}
+		if r.err != nil && (opts.json || opts.jsonMinimal) && errors.Is(r.err, ErrWordNotFound) {
+			// Scripts get not found words in the output, with any suggestions, instead of on stderr
+			writeJSONNotFound(&buf, r.word, r.err)
+			if _, err := out.Write(buf.Bytes()); err != nil {
+				return writeErrorStatus(stderr, err)
+			}
+			if opts.copy {
+				copied.Write(buf.Bytes())
+			}
+			if opts.failFast {
+				return 3
+			}
+			exitCode = 1
+			continue
+		}
+		if r.err != nil {
+			fmt.Fprintf(stderr, "go-dict: %s: %v\n", r.word, r.err)
+			if opts.failFast || (opts.networkFatal && isNetworkError(r.err)) {
+				return 3
+			}
+			exitCode = 1
+			continue
+		}
+		writeEntry(&buf, r.e, names, opts)
+		b := separate(buf.Bytes(), written, opts)
+		written++
+		if _, err := out.Write(b); err != nil {
+			return writeErrorStatus(stderr, err)
+		}
+		if opts.copy {
+			copied.WriteString(stripEscapes(string(b)))
+		}
+	}
+	if inputErr != nil {
+		fmt.Fprintln(stderr, "go-dict: reading stdin:", inputErr)
+		exitCode = 1
+	}
+	if opts.copy && copied.Len() > 0 {
+		// The definitions were still printed, so not being able to copy them isn't an error
+		if err := writeClipboard(copied.String()); err != nil {
+			fmt.Fprintln(stderr, "go-dict: warning:", err)
+		}
+	}
+	return exitCode
+}
 
+// maxReadAhead is how many words past the one being output can be looked up,
+// so a slow word doesn't hold up the rest, but words from stdin aren't all read
+// into memory at once.
+const maxReadAhead = 256
+
+// memoLookup is the lookup of a word that's shared by every time it's in the input.
+type memoLookup struct {
+	done chan struct{} // Closed once r is set
+	r    lookupResult
 }
 
-func main() {
-	if len(os.Args) <= 1 {
-		fmt.Println("Provide a word to lookup.")
-		return
+// result returns a copy of the result, so each time the word is output it can
+// be changed on its own.
+func (m *memoLookup) result() lookupResult {
+	<-m.done
+	r := m.r
+	if r.e != nil {
+		r.e = r.e.clone()
+	}
+	return r
+}
+
+// lookupWords looks up the words from the channel concurrently, at most --concurrency
+// at once, until it's closed. A channel for the result of each word is sent on the
+// returned channel, in the same order as the words, so each result can be output
+// as soon as it and the ones before it are ready.
+// Each word is only looked up once, words that are repeated reuse its result.
+func lookupWords(ctx context.Context, words <-chan string, srcs []Source, c *cache, opts *options) <-chan chan lookupResult {
+	results := make(chan chan lookupResult, maxReadAhead)
+	sem := make(chan struct{}, opts.concurrency) // Limits how many words are looked up at once
+	go func() {
+		defer close(results)
+		memo := make(map[string]*memoLookup)
+		for word := range words {
+			// Phrases are the same however they're spaced, so the banner shows them tidily
+			word = strings.Join(strings.Fields(word), " ")
+			if !opts.caseSensitive {
+				word = strings.ToLower(word)
+			}
+			result := make(chan lookupResult, 1)
+			results <- result
+			if m, ok := memo[word]; ok {
+				go func() { result <- m.result() }()
+				continue
+			}
+			m := &memoLookup{done: make(chan struct{})}
+			memo[word] = m
+			go func(w string) {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				if opts.jitter > 0 && (c == nil || !allCached(w, srcs, opts.thesaurus, c)) {
+					// Spread out the requests of a batch, rather than sending them all at once
+					sleepCtx(ctx, time.Duration(rand.Int63n(int64(opts.jitter))))
+				}
+				m.r = lookupWord(ctx, w, srcs, c, opts)
+				close(m.done)
+				result <- m.result()
+			}(word)
+		}
+	}()
+	return results
+}
+
+// multiLineOutput reports whether the options select an output format with more
+// than one line for each word, where --separator is used.
+func multiLineOutput(opts *options) bool {
+	return !opts.json && !opts.jsonMinimal && !opts.csv && !opts.anki && opts.glossary == "" &&
+		!opts.gloss && !opts.statsByPOS && !opts.check
+}
+
+// separate returns the output of a word with the --separator it needs, where n
+// is how many words were written before it. Entries already end with a blank
+// line, which is what the default separator is, so for the others it's removed,
+// and with rule the line across the terminal goes before every word but the first.
+func separate(b []byte, n int, opts *options) []byte {
+	if opts.separator == "blank" || !multiLineOutput(opts) {
+		return b
+	}
+	var buf bytes.Buffer
+	if opts.separator == "rule" && n > 0 {
+		rule := strings.Repeat("─", opts.termWidth)
+		if !opts.noColor {
+			rule = style("note").Render(rule)
+		}
+		buf.WriteString(rule + "\n")
+	}
+	buf.Write(bytes.TrimRight(b, "\n"))
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// lookupWord looks up a single word in the sources, the way the options say to.
+// Words that can't be looked up because of a network error are looked up in the
+// bundled dictionary instead, if it has them.
+func lookupWord(ctx context.Context, w string, srcs []Source, c *cache, opts *options) lookupResult {
+	if opts.debug {
+		// Watchdog for finding slow words in big batches
+		start := time.Now()
+		t := time.AfterFunc(slowWordThreshold, func() {
+			debugLog.Printf("still waiting on %q after %s", w, time.Since(start).Round(time.Second))
+		})
+		defer t.Stop()
+	}
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	if opts.check {
+		return lookupResult{word: w, e: &entry{word: w}, err: checkAll(ctx, w, srcs, c)}
+	}
+	if opts.prefetch {
+		if allCached(w, srcs, opts.thesaurus, c) {
+			return lookupResult{word: w, cached: true}
+		}
+		_, err := lookupAll(ctx, w, srcs, opts.thesaurus, false, c)
+		return lookupResult{word: w, err: err}
+	}
+	timed := opts.debug && opts.json
+	var cacheHit bool
+	if timed {
+		cacheHit = c != nil && allCached(w, srcs, opts.thesaurus, c)
 	}
-	// TODO: Support multiple words concurrently
-	client := &http.Client{}
-	words := os.Args[1:]
-	// Lookup each word concurrently and store results
-	results := make([]chan []ctxDefinition, 0)
-	for i, word := range words {
-		results = append(results, make(chan []ctxDefinition))
-		go func(ind int, w string) {
-			defs, err := wordnikLookup(w, client)
-			if err != nil {
-				panic(err)
+	start := time.Now()
+	look := func(w string) (*entry, error) {
+		if opts.fallback {
+			return lookupFirst(ctx, w, srcs, opts.thesaurus, opts.stopOnNetworkError, c)
+		}
+		return lookupAll(ctx, w, srcs, opts.thesaurus, opts.agreement, c)
+	}
+	e, err := look(w)
+	if opts.lemmatize && errors.Is(err, ErrWordNotFound) {
+		for _, base := range lemmas(w) {
+			be, bErr := look(base)
+			if bErr == nil {
+				debugLog.Printf("%q wasn't found, using its base form %q", w, base)
+				be.word = w
+				be.lemma = base
+				e, err = be, nil
+				break
+			}
+			if !errors.Is(bErr, ErrWordNotFound) {
+				break
 			}
-			results[ind] <- defs
-		}(i, word)
+		}
+	}
+	if isNetworkError(err) && !opts.thesaurus {
+		// Fall back to the bundled dictionary
+		if oe, oErr := (&offline{}).Lookup(ctx, w); oErr == nil {
+			debugLog.Printf("using the bundled dictionary for %q: %v", w, err)
+			e, err = oe, nil
+		}
+	}
+	if err == nil && opts.frequency {
+		e.frequency = lookupFrequency(ctx, w, srcs)
+	}
+	if err == nil && timed {
+		e.timing = &lookupTiming{took: time.Since(start), cacheHit: cacheHit}
+	}
+	return lookupResult{word: w, e: e, err: err}
+}
+
+// writeEntry writes the entry to out in the output format the options select.
+// The entry's text is sanitized first, so it's safe to show in a terminal.
+func writeEntry(out io.Writer, e *entry, names map[string]string, opts *options) {
+	sanitizeEntry(e)
+	if opts.trimDictNames {
+		trimDictNames(e, names)
+	}
+	filterDefs(e, opts)
+	switch {
+	case opts.jsonMinimal:
+		writeJSONMinimal(out, e, opts)
+	case opts.json:
+		writeJSON(out, e, opts)
+	case opts.csv:
+		writeCSV(out, e, opts)
+	case opts.anki:
+		writeAnki(out, e, opts)
+	case opts.glossary != "":
+		writeGlossaryTerm(out, e, opts)
+	default:
+		pprintEntry(out, e, !opts.noColor, opts)
 	}
+}
+
+// warnFileArgs warns about words that look like paths to files that exist, since
+// those are still looked up as words. Reading words from a file needs --file.
+func warnFileArgs(words []string, stderr io.Writer) {
+	for _, w := range words {
+		if !strings.ContainsAny(w, "./"+string(os.PathSeparator)) {
+			continue
+		}
+		if info, err := os.Stat(w); err == nil && info.Mode().IsRegular() {
+			fmt.Fprintf(stderr, "go-dict: warning: looking up %q as a word, use --file %s to look up the words in the file\n", w, w)
+		}
+	}
+}
+
+// scanWords calls fn with each word read from r, one per line, as soon as its line is read.
+// Blank lines and lines starting with # are skipped.
+func scanWords(r io.Reader, fn func(w string)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			fn(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
 
-	// Print the answer of each word
-	for i, result := range results {
-		// TODO: Write to buffer, then flush after result comes in
-		color.New(color.BgRed, color.White).Println(words[i])
-		pprintCtxDefs(<-result, true)
+// readWordFile returns the words in the file at path, one per line, see scanWords.
+// A path of - reads stdin.
+func readWordFile(path string) ([]string, error) {
+	f := os.Stdin
+	if path != "-" {
+		var err error
+		f, err = os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+	words := make([]string, 0)
+	err := scanWords(f, func(w string) {
+		words = append(words, w)
+	})
+	return words, err
+}
+
+// prefetchReport prints the progress of --prefetch to stderr as each word is done,
+// then a summary of how many words were fetched, already cached, or failed.
+// total is how many words there are, or 0 if that isn't known yet.
+// It returns the exit code, which is 1 if any of them failed.
+func prefetchReport(results <-chan chan lookupResult, total int, stdout, stderr io.Writer) int {
+	var fetched, cached, failed int
+	i := 0
+	for result := range results {
+		r := <-result
+		status := "fetched"
+		switch {
+		case r.err != nil:
+			failed++
+			status = "failed: " + r.err.Error()
+		case r.cached:
+			cached++
+			status = "cached"
+		default:
+			fetched++
+		}
+		i++
+		if total > 0 {
+			fmt.Fprintf(stderr, "[%d/%d] %s: %s\n", i, total, r.word, status)
+		} else {
+			fmt.Fprintf(stderr, "[%d] %s: %s\n", i, r.word, status)
+		}
+	}
+	fmt.Fprintf(stdout, "%d fetched, %d cached, %d failed\n", fetched, cached, failed)
+	if failed > 0 {
+		return 1
 	}
+	return 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], nil, os.Stdout, os.Stderr))
 }
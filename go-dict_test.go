@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testOptions returns the options for the flags, failing the test if they're invalid.
+func testOptions(t *testing.T, args ...string) *options {
+	t.Helper()
+	opts, _, err := parseFlags(append([]string{"--no-color"}, args...), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0 definitions"},
+		{1, "1 definition"},
+		{2, "2 definitions"},
+		{12, "12 definitions"},
+	}
+	for _, tt := range tests {
+		if got := pluralize(tt.n, "definition"); got != tt.want {
+			t.Errorf("pluralize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestMoreDefinitions(t *testing.T) {
+	e := &entry{word: "run", defs: []ctxDefinition{
+		{dict: "Wiktionary", rank: 0, def: definition{text: "One."}},
+		{dict: "Wiktionary", rank: 1, def: definition{text: "Two."}},
+		{dict: "Wiktionary", rank: 2, def: definition{text: "Three."}},
+	}}
+	tests := []struct {
+		head string
+		want string
+	}{
+		{"1", "... 2 more definitions"},
+		{"2", "... 1 more definition"},
+		{"3", ""},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		writeEntry(&out, e.clone(), nil, testOptions(t, "--head", tt.head))
+		got := ""
+		for _, line := range strings.Split(out.String(), "\n") {
+			if strings.HasPrefix(line, "...") {
+				got = line
+			}
+		}
+		if got != tt.want {
+			t.Errorf("--head %s: got %q, want %q", tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestPprintCtxDefsPartial(t *testing.T) {
+	e := &entry{word: "run", defs: []ctxDefinition{
+		{dict: "Wiktionary", rank: 0, def: definition{wordType: "verb", text: "One."}},
+		{dict: "Wiktionary", rank: 1, def: definition{wordType: "verb", text: "Two."}},
+		{dict: "Wiktionary", rank: 2, def: definition{wordType: "noun", text: "Three."}},
+	}}
+	opts := testOptions(t)
+	n := 0
+	opts.number = func(d *definition) int {
+		n++
+		if n == 3 {
+			panic("rendering failed")
+		}
+		return n
+	}
+	var out bytes.Buffer
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("rendering didn't fail")
+			}
+		}()
+		pprintCtxDefs(&out, e, false, opts)
+	}()
+	// The lines are only written when the tabwriter is flushed
+	for _, want := range []string{"1. verb  One.", "2. verb  Two."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("the output before rendering failed is %q, want it to have %q", out.String(), want)
+		}
+	}
+}
+
+func TestExplainOrigin(t *testing.T) {
+	e := &entry{word: "run", defs: []ctxDefinition{
+		{dict: "Wiktionary", rank: 0, def: definition{wordType: "verb", text: "One.", origin: &origin{heading: 0, list: 1, rank: 2}}},
+		{dict: "Bundled", rank: 0, def: definition{wordType: "verb", text: "Two."}},
+	}}
+	var out bytes.Buffer
+	writeEntry(&out, e, nil, testOptions(t, "--explain"))
+	lines := strings.Split(out.String(), "\n")
+	for _, want := range []string{"verb  One.  [heading 0, list 1, rank 2]", "verb  Two."} {
+		found := false
+		for _, line := range lines {
+			if strings.TrimRight(line, " ") == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("there's no line %q in\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDictOrderCase(t *testing.T) {
+	// The collator ignores case, so these are only told apart by the tiebreak
+	m := map[string][]definition{"wiktionary": nil, "Wiktionary": nil, "WIKTIONARY": nil, "Century": nil}
+	want := "Century WIKTIONARY Wiktionary wiktionary"
+	opts := testOptions(t, "--locale", "en")
+	// Map order is random, so each time the names are in a different order before sorting
+	for i := 0; i < 50; i++ {
+		if got := strings.Join(dictOrder(m, opts), " "); got != want {
+			t.Fatalf("order is %q, want %q", got, want)
+		}
+	}
+}
+
+// closer is an io.Closer that returns err.
+type closer struct{ err error }
+
+func (c closer) Close() error { return c.err }
+
+func TestCloseOutput(t *testing.T) {
+	tests := []struct {
+		err    error
+		code   int
+		want   int
+		stderr string
+	}{
+		{nil, 0, 0, ""},
+		{nil, 1, 1, ""},
+		{errors.New("disk quota exceeded"), 0, 1, "go-dict: writing output: disk quota exceeded\n"},
+		// The failure that came first is kept
+		{errors.New("disk quota exceeded"), 3, 3, "go-dict: writing output: disk quota exceeded\n"},
+	}
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		if got := closeOutput(closer{tt.err}, tt.code, &stderr); got != tt.want {
+			t.Errorf("closeOutput(%v, %d) = %d, want %d", tt.err, tt.code, got, tt.want)
+		}
+		if stderr.String() != tt.stderr {
+			t.Errorf("closeOutput(%v, %d) wrote %q, want %q", tt.err, tt.code, stderr.String(), tt.stderr)
+		}
+	}
+}
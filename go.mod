@@ -1,9 +1,13 @@
 module github.com/makeworld-the-better-one/go-dict
 
-go 1.14
+go 1.16
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/abadojack/whatlanggo v1.0.1
+	github.com/peterh/liner v1.2.2
 	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
+	golang.org/x/text v0.3.2
 	gopkg.in/gookit/color.v1 v1.1.6
 )
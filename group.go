@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+)
+
+// group is a heading and the definitions displayed under it.
+type group struct {
+	heading   string // Empty if the group has no heading
+	homograph int    // The homograph number of the definitions, if they're all of one
+	// attribution is the credit the dictionary of the definitions needs, if they're all from one
+	attribution string
+	defs        []definition
+}
+
+// grouper splits ctxDefinitions up into ordered groups for display.
+type grouper func(cDs []ctxDefinition, opts *options) []group
+
+// groupers maps the values of --group-by to the grouping strategy they select.
+var groupers = map[string]grouper{
+	"dictionary": groupByDictionary,
+	"pos":        groupByPOS,
+	"none":       groupNone,
+}
+
+// getGrouper returns the grouping strategy with the provided name.
+func getGrouper(name string) (grouper, error) {
+	g, ok := groupers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown grouping %q, must be dictionary, pos, or none", name)
+	}
+	return g, nil
+}
+
+// limitGroups returns the first --limit-dictionaries groups that have any
+// definitions, and how many more of them were left out.
+func limitGroups(groups []group, opts *options) ([]group, int) {
+	ret := make([]group, 0, len(groups))
+	for _, g := range groups {
+		if len(g.defs) > 0 {
+			ret = append(ret, g)
+		}
+	}
+	if opts.limitDicts > 0 && len(ret) > opts.limitDicts {
+		return ret[:opts.limitDicts], len(ret) - opts.limitDicts
+	}
+	return ret, 0
+}
+
+// moreGroupsNote returns the note shown when n groups were left out by limitGroups.
+func moreGroupsNote(n int, opts *options) string {
+	switch {
+	case opts.groupBy != "dictionary":
+		return "... " + pluralize(n, "more group")
+	case n == 1:
+		return "... 1 more dictionary"
+	}
+	return fmt.Sprintf("... %d more dictionaries", n)
+}
+
+// groupByDictionary groups definitions by the dictionary they come from, with
+// a separate group for each homograph. This is the default.
+func groupByDictionary(cDs []ctxDefinition, opts *options) []group {
+	m := byDictionary(cDs, opts.dedupWithinDict)
+	homographs := make(map[string]int)
+	attributions := make(map[string]string)
+	for _, cD := range cDs {
+		homographs[cD.groupName()] = cD.homograph
+		if cD.attribution != "" {
+			attributions[cD.groupName()] = cD.attribution
+		}
+	}
+	groups := make([]group, 0, len(m))
+	for _, dict := range dictOrder(m, opts) {
+		groups = append(groups, group{
+			heading:     dict,
+			homograph:   homographs[dict],
+			attribution: attributions[dict],
+			defs:        m[dict],
+		})
+	}
+	return groups
+}
+
+// groupByPOS groups definitions by their word type (part of speech), with the
+// word types in the order they first appear in the normal output.
+func groupByPOS(cDs []ctxDefinition, opts *options) []group {
+	groups := make([]group, 0)
+	index := make(map[string]int)
+	for _, cD := range orderedCtxDefs(cDs, opts) {
+		wT := cD.def.wordType
+		if wT == "" {
+			wT = "other"
+		}
+		i, ok := index[wT]
+		if !ok {
+			i = len(groups)
+			index[wT] = i
+			groups = append(groups, group{heading: wT})
+		}
+		groups[i].defs = append(groups[i].defs, cD.def)
+	}
+	return groups
+}
+
+// groupNone puts all the definitions into a single group without a heading.
+func groupNone(cDs []ctxDefinition, opts *options) []group {
+	defs := make([]definition, 0, len(cDs))
+	for _, cD := range orderedCtxDefs(cDs, opts) {
+		defs = append(defs, cD.def)
+	}
+	return []group{{defs: defs}}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"golang.org/x/net/http/httpguts"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrRateLimited is returned when a server keeps responding with 429 after all retries.
+	ErrRateLimited = errors.New("rate limited by the server, try again later")
+)
+
+const (
+	rateLimitRetries = 3                // How many times a 429 is retried
+	maxRetryAfter    = 30 * time.Second // Cap on how long a Retry-After can make us sleep
+)
+
+// retryAfter returns how long to wait before retrying a 429 response.
+// The Retry-After header can be in seconds or an HTTP date, and falls back
+// to an exponential backoff based on the attempt number. The result is capped.
+func retryAfter(resp *http.Response, attempt int) time.Duration {
+	d := time.Second << uint(attempt)
+	if h := resp.Header.Get("Retry-After"); h != "" {
+		if secs, err := strconv.Atoi(h); err == nil && secs >= 0 {
+			d = time.Duration(secs) * time.Second
+		} else if t, err := http.ParseTime(h); err == nil {
+			d = time.Until(t)
+		}
+	}
+	if d < 0 {
+		d = 0
+	}
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+	return d
+}
+
+// sleepCtx sleeps for d, returning early with the context's error if it's cancelled.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// isRetryable reports whether a request can safely be sent again.
+// Requests with idempotent methods like GET can always be retried. Other requests,
+// like POST, are only retried if they have an Idempotency-Key header, which marks
+// them as safe to repeat. This is the same convention net/http uses.
+// A request with a body also needs GetBody set, so the body can be sent again.
+func isRetryable(req *http.Request) bool {
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+	default:
+		if req.Header.Get("Idempotency-Key") == "" && req.Header.Get("X-Idempotency-Key") == "" {
+			return false
+		}
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// doRequest sends the request with the client, and is what sources should use
+// to make their requests.
+// If the server responds with 429 and the request is retryable, it waits and
+// retries, returning ErrRateLimited if it's still rate limited after all retries.
+// Non-retryable requests get ErrRateLimited on the first 429.
+func doRequest(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	retryable := isRetryable(req)
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, redactQuery(err)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if !retryable || attempt >= rateLimitRetries {
+			return nil, ErrRateLimited
+		}
+		if err := sleepCtx(ctx, retryAfter(resp, attempt)); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// redactQuery removes the query from the URL in a request's error, since it can have
+// an API key in it, which mustn't end up in error messages or the debug log.
+func redactQuery(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	u, pErr := url.Parse(urlErr.URL)
+	if pErr != nil {
+		// Don't risk leaving the key in a URL that can't be parsed
+		return urlErr.Err
+	}
+	if u.RawQuery == "" {
+		return err
+	}
+	u.RawQuery = ""
+	return &url.Error{Op: urlErr.Op, URL: u.String(), Err: urlErr.Err}
+}
+
+// connectProblem describes why a request couldn't reach the server, for error messages.
+// It tells apart DNS failures, refused and reset connections, TLS errors, and timeouts,
+// so it's clear whether the problem is the local network, DNS, or the server being down.
+func connectProblem(err error) string {
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return "DNS lookup failed, " + dnsErr.Name + " wasn't found"
+		}
+		return "DNS lookup of " + dnsErr.Name + " failed, check your network or DNS server"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused, the server may be down"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset by the server"
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return "network unreachable, check your connection"
+	case errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return "TLS certificate couldn't be verified"
+	case errors.As(err, &recordErr):
+		return "TLS handshake failed, the server didn't respond with TLS"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out"
+	}
+	return err.Error()
+}
+
+// defaultMaxResponseSize is the biggest response body that's read by default.
+const defaultMaxResponseSize = 5 << 20
+
+// ErrResponseTooLarge is returned when a response body is bigger than --max-response-size.
+var ErrResponseTooLarge = errors.New("the response is bigger than --max-response-size")
+
+// parseError returns the error for a response body that couldn't be parsed, which
+// is err itself if the body was too large, and the malformed message otherwise.
+func parseError(err error, malformed string) error {
+	if errors.Is(err, ErrResponseTooLarge) {
+		return err
+	}
+	return errors.New(malformed)
+}
+
+// limitedBody is a response body that returns ErrResponseTooLarge when more than
+// n bytes are read from it, so a huge response can't use up all the memory.
+type limitedBody struct {
+	io.ReadCloser
+	n int64 // Bytes that can still be read
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		// Check whether there's more before failing, the body could be exactly the limit
+		var one [1]byte
+		n, err := b.ReadCloser.Read(one[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
+
+// limitTransport is an http.RoundTripper that limits the size of response bodies to max bytes.
+type limitTransport struct {
+	max  int64
+	base http.RoundTripper
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, n: t.max}
+	return resp, nil
+}
+
+// byteSize is the value of a flag that's a number of bytes, like "512KB" or "5MB".
+type byteSize int64
+
+func (s *byteSize) String() string {
+	switch n := int64(*s); {
+	case n != 0 && n%(1<<20) == 0:
+		return strconv.FormatInt(n>>20, 10) + "MB"
+	case n != 0 && n%(1<<10) == 0:
+		return strconv.FormatInt(n>>10, 10) + "KB"
+	default:
+		return strconv.FormatInt(n, 10)
+	}
+}
+
+// Set parses a number of bytes, with an optional KB, MB or GB suffix, which are powers of 1024.
+func (s *byteSize) Set(v string) error {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	mult := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(v, unit.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, unit.suffix))
+			mult = unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return errors.New("must be a number of bytes, like 512KB or 5MB")
+	}
+	*s = byteSize(n * mult)
+	return nil
+}
+
+// headerList is the value of the repeatable --header flag, the extra headers sent with every request.
+type headerList http.Header
+
+func (h headerList) String() string {
+	parts := make([]string, 0, len(h))
+	for name, values := range h {
+		for _, v := range values {
+			parts = append(parts, name+": "+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Set adds a header in the form "Name: Value".
+func (h headerList) Set(s string) error {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return fmt.Errorf("invalid header %q, must be like \"Name: Value\"", s)
+	}
+	name, value := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if !httpguts.ValidHeaderFieldName(name) {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	if !httpguts.ValidHeaderFieldValue(value) {
+		return fmt.Errorf("invalid value for header %s", name)
+	}
+	http.Header(h).Add(name, value)
+	return nil
+}
+
+// tlsTransport returns a copy of http.DefaultTransport with its TLS settings changed.
+// The certificates in the PEM file at caCert, if there is one, are trusted along
+// with the system's, and if insecure is true, certificates aren't verified at all.
+func tlsTransport(caCert string, insecure bool) (*http.Transport, error) {
+	conf := &tls.Config{InsecureSkipVerify: insecure}
+	if caCert != "" {
+		pem, err := ioutil.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the CA certificates: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in %s", caCert)
+		}
+		conf.RootCAs = pool
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = conf
+	return t, nil
+}
+
+// headerTransport is an http.RoundTripper that sets extra headers on every request.
+// They replace any headers of the same name the sources set, like the User-Agent.
+type headerTransport struct {
+	headers http.Header
+	base    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+// withHeader returns a copy of client that also sends the header with every request.
+// Headers from --header are set after it, so they replace it. If value is empty,
+// client is returned as it is.
+func withHeader(client *http.Client, name, value string) *http.Client {
+	if value == "" {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = &headerTransport{headers: http.Header{http.CanonicalHeaderKey(name): {value}}, base: base}
+	return &c
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// fakeDialer returns a client whose connections all fail with err.
+func fakeDialer(err error) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, err
+		},
+	}}
+}
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestConnectProblem(t *testing.T) {
+	opErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "not found",
+			err:  opErr(&net.DNSError{Err: "no such host", Name: "www.wordnik.com", IsNotFound: true}),
+			want: "DNS lookup failed, www.wordnik.com wasn't found",
+		},
+		{
+			name: "DNS server",
+			err:  opErr(&net.DNSError{Err: "server misbehaving", Name: "www.wordnik.com"}),
+			want: "DNS lookup of www.wordnik.com failed, check your network or DNS server",
+		},
+		{
+			name: "refused",
+			err:  opErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)),
+			want: "connection refused, the server may be down",
+		},
+		{
+			name: "unreachable",
+			err:  opErr(os.NewSyscallError("connect", syscall.ENETUNREACH)),
+			want: "network unreachable, check your connection",
+		},
+		{
+			name: "timeout",
+			err:  opErr(timeoutError{}),
+			want: "timed out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := wordnikLookup(context.Background(), "http://www.wordnik.com/words/", "run", fakeDialer(tt.err))
+			if !errors.Is(err, ErrNetwork) {
+				t.Fatalf("error is %v, want ErrNetwork", err)
+			}
+			if want := "couldn't connect to wordnik: " + tt.want; err.Error() != want {
+				t.Errorf("error is %q, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestConnectProblemTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0) // The failed handshake is logged
+	ts.StartTLS()
+	defer ts.Close()
+	_, err := wordnikLookup(context.Background(), ts.URL+"/words/", "run", &http.Client{})
+	if !errors.Is(err, ErrNetwork) {
+		t.Fatalf("error is %v, want ErrNetwork", err)
+	}
+	if want := "couldn't connect to wordnik: TLS certificate couldn't be verified"; err.Error() != want {
+		t.Errorf("error is %q, want %q", err, want)
+	}
+}
+
+// headerServer is a wordnik server that records the headers of the last request.
+func headerServer(t *testing.T) (*httptest.Server, *http.Header) {
+	t.Helper()
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write(fixture(t, "wordnik/run.html"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &got
+}
+
+func TestHeaderFlag(t *testing.T) {
+	ts, got := headerServer(t)
+	_, stderr, code := runWordnik(t, ts,
+		"--header", "X-Debug: 1",
+		"--header", "X-Debug: 2",
+		"--header", "User-Agent:  go-dict-test ",
+		"run")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	if v := (*got)["X-Debug"]; len(v) != 2 || v[0] != "1" || v[1] != "2" {
+		t.Errorf("X-Debug headers are %q, want both of the ones given", v)
+	}
+	// The default User-Agent is replaced
+	if ua := got.Get("User-Agent"); ua != "go-dict-test" {
+		t.Errorf("User-Agent is %q, want go-dict-test", ua)
+	}
+}
+
+func TestHeaderFlagInvalid(t *testing.T) {
+	ts, _ := headerServer(t)
+	for _, h := range []string{"no colon", "Bad Name: value", ": value", "X-Debug: a\nb"} {
+		if err := make(headerList).Set(h); err == nil {
+			t.Errorf("--header %q was accepted", h)
+		}
+		if _, _, code := runWordnik(t, ts, "--header", h, "run"); code != 2 {
+			t.Errorf("--header %q: exit status is %d, want 2", h, code)
+		}
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	page := fixture(t, "wordnik/run.html")
+	// Padding after the page, like a server that keeps sending
+	padded := append(append([]byte(nil), page...), bytes.Repeat([]byte("<!-- padding -->\n"), 1<<12)...)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(padded)
+	}))
+	defer ts.Close()
+
+	stdout, stderr, code := runWordnik(t, ts, "--max-response-size", "16KB", "run")
+	if code != 1 || stdout != "" {
+		t.Errorf("run wrote %q and exited with %d, want nothing and 1", stdout, code)
+	}
+	if want := "go-dict: run: the response is bigger than --max-response-size\n"; stderr != want {
+		t.Errorf("stderr is %q, want %q", stderr, want)
+	}
+	// The default is big enough
+	if _, stderr, code := runWordnik(t, ts, "run"); code != 0 {
+		t.Errorf("exit status %d with the default size: %s", code, stderr)
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	for _, tt := range []struct {
+		n   int64
+		err error
+	}{
+		{99, ErrResponseTooLarge},
+		{100, nil},
+		{101, nil},
+	} {
+		b := &limitedBody{ReadCloser: ioutil.NopCloser(strings.NewReader(body)), n: tt.n}
+		got, err := ioutil.ReadAll(b)
+		if err != tt.err {
+			t.Errorf("reading %d bytes with a limit of %d: error is %v, want %v", len(body), tt.n, err, tt.err)
+		}
+		if tt.err == nil && string(got) != body {
+			t.Errorf("reading with a limit of %d got %d bytes, want all %d", tt.n, len(got), len(body))
+		}
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	ts, got := headerServer(t)
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{"--accept-language", "en-GB,en;q=0.8"}, []string{"en-GB,en;q=0.8"}},
+		// --header replaces it
+		{[]string{"--accept-language", "fr", "--header", "Accept-Language: de"}, []string{"de"}},
+	}
+	for _, tt := range tests {
+		_, stderr, code := runWordnik(t, ts, append(tt.args, "run")...)
+		if code != 0 {
+			t.Fatalf("%v: exit status %d: %s", tt.args, code, stderr)
+		}
+		if v := (*got)["Accept-Language"]; !reflect.DeepEqual(v, tt.want) {
+			t.Errorf("%v: Accept-Language is %q, want %q", tt.args, v, tt.want)
+		}
+	}
+	for _, lang := range []string{"not a language!", "en;q=abc"} {
+		if _, _, code := runWordnik(t, ts, "--accept-language", lang, "run"); code != 2 {
+			t.Errorf("--accept-language %q: exit status is %d, want 2", lang, code)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"github.com/abadojack/whatlanggo"
+	"sort"
+	"strings"
+)
+
+// defaultLangConfidence is how confident the detection has to be that a definition
+// is in another language for --lang to drop it, by default.
+const defaultLangConfidence = whatlanggo.ReliableConfidenceThreshold
+
+// parseLang returns the ISO 639-1 code for a --lang value, checking that it's
+// a language that can be detected. An empty value turns the filtering off.
+func parseLang(code string) (string, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return "", nil
+	}
+	codes := make([]string, 0, len(whatlanggo.Langs))
+	for l := range whatlanggo.Langs {
+		if c := l.Iso6391(); c == code {
+			return code, nil
+		} else if c != "" {
+			codes = append(codes, c)
+		}
+	}
+	sort.Strings(codes)
+	return "", fmt.Errorf("unknown language %q for --lang, valid languages are: %s", code, strings.Join(codes, ", "))
+}
+
+// inLang reports whether the text could be in the language with the ISO 639-1 code.
+// It's only false when the text is detected as another language with at least
+// the confidence given, so short or mixed text that can't be told apart is kept.
+func inLang(text, code string, confidence float64) bool {
+	info := whatlanggo.Detect(text)
+	if info.Confidence < confidence {
+		return true
+	}
+	return info.Lang.Iso6391() == code
+}
+
+// inLangDefs returns only the ctxDefinitions whose text could be in the language, for --lang.
+func inLangDefs(cDs []ctxDefinition, code string, confidence float64) []ctxDefinition {
+	ret := make([]ctxDefinition, 0, len(cDs))
+	for _, cD := range cDs {
+		if inLang(cD.def.text, code, confidence) {
+			ret = append(ret, cD)
+		} else {
+			debugLog.Printf("dropping definition from %s not detected as %s: %q", cD.dict, code, cD.def.text)
+		}
+	}
+	return ret
+}
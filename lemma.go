@@ -0,0 +1,196 @@
+package main
+
+import "strings"
+
+// irregularForms maps irregular inflections of words to their base form, for
+// --lemmatize. Regular inflections are handled by suffixRules instead.
+var irregularForms = map[string]string{
+	// Plurals
+	"mice":      "mouse",
+	"lice":      "louse",
+	"geese":     "goose",
+	"feet":      "foot",
+	"teeth":     "tooth",
+	"men":       "man",
+	"women":     "woman",
+	"children":  "child",
+	"people":    "person",
+	"oxen":      "ox",
+	"dice":      "die",
+	"criteria":  "criterion",
+	"phenomena": "phenomenon",
+	"cacti":     "cactus",
+	"fungi":     "fungus",
+	"nuclei":    "nucleus",
+	"analyses":  "analysis",
+	"crises":    "crisis",
+	"theses":    "thesis",
+	// Verbs
+	"am":         "be",
+	"is":         "be",
+	"are":        "be",
+	"was":        "be",
+	"were":       "be",
+	"been":       "be",
+	"has":        "have",
+	"had":        "have",
+	"does":       "do",
+	"did":        "do",
+	"done":       "do",
+	"went":       "go",
+	"gone":       "go",
+	"ran":        "run",
+	"saw":        "see",
+	"seen":       "see",
+	"took":       "take",
+	"taken":      "take",
+	"gave":       "give",
+	"given":      "give",
+	"came":       "come",
+	"knew":       "know",
+	"known":      "know",
+	"thought":    "think",
+	"brought":    "bring",
+	"bought":     "buy",
+	"caught":     "catch",
+	"taught":     "teach",
+	"wrote":      "write",
+	"written":    "write",
+	"ate":        "eat",
+	"eaten":      "eat",
+	"spoke":      "speak",
+	"spoken":     "speak",
+	"began":      "begin",
+	"begun":      "begin",
+	"swam":       "swim",
+	"swum":       "swim",
+	"sang":       "sing",
+	"sung":       "sing",
+	"drank":      "drink",
+	"drunk":      "drink",
+	"flew":       "fly",
+	"flown":      "fly",
+	"drove":      "drive",
+	"driven":     "drive",
+	"rode":       "ride",
+	"ridden":     "ride",
+	"froze":      "freeze",
+	"frozen":     "freeze",
+	"chose":      "choose",
+	"chosen":     "choose",
+	"fell":       "fall",
+	"fallen":     "fall",
+	"left":       "leave",
+	"felt":       "feel",
+	"kept":       "keep",
+	"slept":      "sleep",
+	"made":       "make",
+	"said":       "say",
+	"paid":       "pay",
+	"found":      "find",
+	"told":       "tell",
+	"sold":       "sell",
+	"stood":      "stand",
+	"understood": "understand",
+	// Adjectives
+	"better": "good",
+	"best":   "good",
+	"worse":  "bad",
+	"worst":  "bad",
+}
+
+// suffixRule turns a regular inflection into a possible base form, by replacing
+// the suffix with replace.
+type suffixRule struct {
+	suffix  string
+	replace string
+}
+
+// suffixRules are tried in order on words that aren't in irregularForms, and every
+// rule that matches gives a candidate, since the spelling alone often can't tell
+// which one is right, like "baked" (bake) and "walked" (walk).
+var suffixRules = []suffixRule{
+	{"ies", "y"},  // flies
+	{"ves", "f"},  // leaves
+	{"ves", "fe"}, // knives
+	{"sses", "ss"},
+	{"shes", "sh"},
+	{"ches", "ch"},
+	{"xes", "x"},
+	{"zes", "z"},
+	{"oes", "o"}, // potatoes
+	{"s", ""},
+	{"ied", "y"}, // carried
+	{"ed", ""},
+	{"ed", "e"}, // baked
+	{"ing", ""},
+	{"ing", "e"}, // making
+	{"ier", "y"}, // happier
+	{"iest", "y"},
+	{"er", ""},
+	{"er", "e"}, // later
+	{"est", ""},
+	{"est", "e"},
+}
+
+// maxLemmas is how many base forms --lemmatize tries at most, since each one
+// that doesn't exist is another round of requests.
+const maxLemmas = 3
+
+// lemmas returns the possible base forms of an inflected word, most likely first,
+// for --lemmatize. Doubled final consonants are undone, so "stopped" gives "stop",
+// and an e that was dropped is tried first when it likely was, so "baked" gives "bake".
+func lemmas(w string) []string {
+	lower := strings.ToLower(w)
+	if base, ok := irregularForms[lower]; ok {
+		return []string{base}
+	}
+	var ret []string
+	seen := map[string]bool{lower: true}
+	add := func(base string) {
+		if len(base) < 2 || seen[base] || len(ret) == maxLemmas {
+			return
+		}
+		seen[base] = true
+		ret = append(ret, base)
+	}
+	for _, r := range suffixRules {
+		if !strings.HasSuffix(lower, r.suffix) || (r.suffix == "s" && strings.HasSuffix(lower, "ss")) {
+			continue
+		}
+		stem := lower[:len(lower)-len(r.suffix)]
+		if r.replace == "" || r.replace == "e" {
+			if doubledConsonant(stem) {
+				// Like "stopped" or "running"
+				add(stem[:len(stem)-1])
+				continue
+			}
+			if r.replace == "" && r.suffix != "s" && endsCVC(stem) {
+				// Like "baked" or "making", which are more likely to have lost an e
+				add(stem + "e")
+			}
+		}
+		add(stem + r.replace)
+	}
+	return ret
+}
+
+// endsCVC reports whether s ends in a consonant, a single vowel and a consonant.
+func endsCVC(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+	vowel := func(b byte) bool { return strings.IndexByte("aeiou", b) >= 0 }
+	n := len(s)
+	return !vowel(s[n-3]) && vowel(s[n-2]) && !vowel(s[n-1]) && s[n-1] != 'w' && s[n-1] != 'x' && s[n-1] != 'y'
+}
+
+// doubledConsonant reports whether s ends in the same consonant twice, other than
+// ones that are usually doubled in the base form too, like "ll" in "call".
+func doubledConsonant(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+	last := s[len(s)-1]
+	return last == s[len(s)-2] && !strings.ContainsRune("aeiouyslfz", rune(last))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"os"
+	"strings"
+)
+
+// systemLocale returns the user's locale from the environment, as a BCP 47 tag like "en-US".
+// It returns an empty string if no locale is set, if it's the C or POSIX locale,
+// or if it isn't valid, so a broken environment doesn't stop go-dict from running.
+func systemLocale() string {
+	for _, v := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		loc := os.Getenv(v)
+		if loc == "" {
+			continue
+		}
+		// Strip the encoding and modifier, like in "fr_CA.UTF-8@euro"
+		if i := strings.IndexAny(loc, ".@"); i >= 0 {
+			loc = loc[:i]
+		}
+		if loc == "C" || loc == "POSIX" {
+			return ""
+		}
+		loc = strings.Replace(loc, "_", "-", -1)
+		if _, err := language.Parse(loc); err != nil {
+			return ""
+		}
+		return loc
+	}
+	return ""
+}
+
+// parseLocale returns the language tag for a --locale value.
+// An empty locale is language.Und, which sorts by the root collation order.
+func parseLocale(loc string) (language.Tag, error) {
+	if loc == "" {
+		return language.Und, nil
+	}
+	tag, err := language.Parse(loc)
+	if err != nil {
+		return language.Und, fmt.Errorf("invalid locale %q: %v", loc, err)
+	}
+	return tag, nil
+}
+
+// newCollator returns a collator for sorting strings alphabetically in the locale,
+// ignoring case. Strings that only differ in case compare equal, so sorts should
+// use collateLess, which breaks the tie.
+// A collator can't be used concurrently, so each sort should get its own.
+func newCollator(tag language.Tag) *collate.Collator {
+	return collate.New(tag, collate.IgnoreCase)
+}
+
+// collateLess reports whether a sorts before b with the collator, comparing the
+// bytes of strings it sees as equal, so the order is always the same.
+func collateLess(col *collate.Collator, a, b string) bool {
+	if c := col.CompareString(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setenv sets an environment variable for the rest of the test.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// isolate points the config, cache and data directories at a temporary directory,
+// so tests don't use or change the real ones.
+func isolate(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	setenv(t, "HOME", dir)
+	setenv(t, "XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	setenv(t, "XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	setenv(t, "XDG_DATA_HOME", filepath.Join(dir, "data"))
+	return dir
+}
+
+// fixture returns the contents of a file in testdata.
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// wordnikServer serves the wordnik fixture pages, by the word they're for.
+// Other words are 404s, like on wordnik.
+func wordnikServer(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[strings.TrimPrefix(r.URL.Path, "/words/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(fixture(t, filepath.Join("wordnik", page)))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// runWordnik runs go-dict with the args against the wordnik server, without a cache
+// or colors, and returns what it wrote and its exit status.
+func runWordnik(t *testing.T, ts *httptest.Server, args ...string) (string, string, int) {
+	t.Helper()
+	isolate(t)
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"--no-cache", "--no-color"}, args...)
+	code := run(args, map[string]string{"wordnik": ts.URL + "/words/"}, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+const runOutput = `run
+The American Heritage® Dictionary of the English Language, 5th Edition
+intransitive verb  To move swiftly on foot so that both feet leave the ground during each stride.
+intransitive verb  To move without hindrance or restraint.
+noun               A score in baseball made by advancing around the bases.
+
+Wiktionary, Creative Commons Attribution/Share-Alike License
+noun  (informal) A short trip.
+
+`
+
+func TestRun(t *testing.T) {
+	ts := wordnikServer(t, map[string]string{"run": "run.html", "walk": "walk.html"})
+	tests := []struct {
+		name   string
+		args   []string
+		stdout string
+		stderr string
+		code   int
+	}{
+		{
+			name:   "word",
+			args:   []string{"run"},
+			stdout: runOutput,
+		},
+		{
+			name:   "several words",
+			args:   []string{"run", "walk"},
+			stdout: runOutput + "walk\nWiktionary\nverb  To move on foot at a pace slower than a run.\n\n",
+		},
+		{
+			name:   "not found",
+			args:   []string{"run", "zzz"},
+			stdout: runOutput,
+			stderr: "go-dict: zzz: word not found\n",
+			code:   1,
+		},
+		{
+			name:   "fail fast",
+			args:   []string{"--fail-fast", "zzz", "run"},
+			stderr: "go-dict: zzz: word not found\n",
+			code:   3,
+		},
+		{
+			name:   "thesaurus",
+			args:   []string{"--thesaurus", "run"},
+			stdout: "run\nSynonyms\nsprint, dash\n\n",
+		},
+		{
+			name: "head",
+			args: []string{"--head", "1", "run"},
+			stdout: "run\nThe American Heritage® Dictionary of the English Language, 5th Edition\n" +
+				"intransitive verb  To move swiftly on foot so that both feet leave the ground during each stride.\n" +
+				"... 2 more definitions\n" +
+				"\nWiktionary, Creative Commons Attribution/Share-Alike License\nnoun  (informal) A short trip.\n\n",
+		},
+		{
+			name:   "no words",
+			stdout: "Provide a word to lookup.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runWordnik(t, ts, tt.args...)
+			if stdout != tt.stdout {
+				t.Errorf("stdout is\n%s\nwant\n%s", stdout, tt.stdout)
+			}
+			if stderr != tt.stderr {
+				t.Errorf("stderr is %q, want %q", stderr, tt.stderr)
+			}
+			if code != tt.code {
+				t.Errorf("exit status is %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestRunBadFlags(t *testing.T) {
+	ts := wordnikServer(t, nil)
+	for _, args := range [][]string{
+		{"--head", "-1", "run"},
+		{"--tail", "-1", "run"},
+		{"--best", "-1", "run"},
+		{"--max-total", "-1", "run"},
+		{"--limit-dictionaries", "-1", "run"},
+		{"--head", "1", "--tail", "1", "run"},
+		{"--no-such-flag", "run"},
+	} {
+		stdout, stderr, code := runWordnik(t, ts, args...)
+		if code != 2 {
+			t.Errorf("%v: exit status is %d, want 2", args, code)
+		}
+		if stdout != "" {
+			t.Errorf("%v: wrote %q to stdout", args, stdout)
+		}
+		if stderr == "" {
+			t.Errorf("%v: didn't say what was wrong", args)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	_ "embed" // For the bundled dictionary
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bundledDict is the dictionary name of definitions from the bundled dictionary.
+const bundledDict = "Bundled"
+
+// bundledData is a small dictionary of common words that's built into go-dict,
+// so it works without a network connection.
+// Each line is a definition, with the word, word type and text separated by tabs.
+// The lines are sorted by word, and the definitions of each word are in rank order.
+//
+//go:embed data/bundled.tsv
+var bundledData string
+
+var (
+	bundledOnce  sync.Once
+	bundledLines []string
+)
+
+// bundledWord returns the word a line of bundledData is for.
+func bundledWord(line string) string {
+	if i := strings.IndexByte(line, '\t'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// bundledSearch returns the index of the first line of bundledData for a word
+// that's not before w alphabetically. The lines are sorted, so this is a binary search.
+func bundledSearch(w string) int {
+	bundledOnce.Do(func() {
+		bundledLines = strings.Split(strings.TrimSpace(bundledData), "\n")
+	})
+	return sort.Search(len(bundledLines), func(i int) bool {
+		return bundledWord(bundledLines[i]) >= w
+	})
+}
+
+// bundledLookup returns the definitions for the word from the bundled dictionary.
+func bundledLookup(w string) []ctxDefinition {
+	w = strings.ToLower(w)
+	i := bundledSearch(w)
+	ret := make([]ctxDefinition, 0)
+	for ; i < len(bundledLines) && bundledWord(bundledLines[i]) == w; i++ {
+		fields := strings.SplitN(bundledLines[i], "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		ret = append(ret, ctxDefinition{
+			dict: bundledDict,
+			rank: len(ret),
+			def: definition{
+				wordType: fields[1],
+				text:     fields[2],
+			},
+		})
+	}
+	return ret
+}
+
+// bundledWords returns the words in the bundled dictionary that start with prefix.
+func bundledWords(prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	words := make([]string, 0)
+	for i := bundledSearch(prefix); i < len(bundledLines); i++ {
+		w := bundledWord(bundledLines[i])
+		if !strings.HasPrefix(w, prefix) {
+			break
+		}
+		if len(words) == 0 || words[len(words)-1] != w {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// bundledWordOfTheDay returns the word of the bundled dictionary for the day t is in.
+// The same word is returned all day, and the words are gone through in a shuffled order.
+func bundledWordOfTheDay(t time.Time) string {
+	words := bundledWords("")
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+	perm := rand.New(rand.NewSource(day / int64(len(words)))).Perm(len(words))
+	return words[perm[day%int64(len(words))]]
+}
+
+// offline is the Source for the bundled dictionary, which needs no network.
+type offline struct{}
+
+func (o *offline) Name() string {
+	return "offline"
+}
+
+func (o *offline) Lookup(ctx context.Context, w string) (*entry, error) {
+	defs := bundledLookup(w)
+	if len(defs) == 0 {
+		return nil, ErrWordNotFound
+	}
+	return &entry{word: w, formOf: findFormOf(w, defs), defs: defs}, nil
+}
+
+func (o *offline) Complete(ctx context.Context, prefix string) ([]string, error) {
+	return bundledWords(prefix), nil
+}
+
+func (o *offline) RandomWord(ctx context.Context) (string, error) {
+	words := bundledWords("")
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return words[r.Intn(len(words))], nil
+}
+
+func (o *offline) Reverse(ctx context.Context, query string, limit int) ([]string, error) {
+	return bundledReverse(query, limit), nil
+}
+
+func (o *offline) WordOfTheDay(ctx context.Context) (string, error) {
+	return bundledWordOfTheDay(time.Now()), nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonDefinition is how a ctxDefinition is represented in JSON output.
+type jsonDefinition struct {
+	Dictionary  string   `json:"dictionary"`
+	Rank        int      `json:"rank"`
+	Homograph   int      `json:"homograph,omitempty"`
+	WordType    string   `json:"word_type"`
+	POSFull     string   `json:"pos_full"`
+	POSAbbrev   string   `json:"pos_abbrev"`
+	Label       string   `json:"label,omitempty"`
+	Text        string   `json:"text"`
+	Examples    []string `json:"examples,omitempty"`
+	SourceURL   string   `json:"source_url"`
+	Attribution string   `json:"attribution,omitempty"`
+	AgreedBy    []string `json:"agreed_by,omitempty"`
+}
+
+// jsonEntry is how an entry is represented in JSON output.
+type jsonEntry struct {
+	Word        string           `json:"word"`
+	FormOf      string           `json:"form_of,omitempty"`
+	BaseForm    string           `json:"base_form,omitempty"`
+	SourceURL   string           `json:"source_url"`
+	Frequency   int              `json:"frequency,omitempty"`
+	Definitions []jsonDefinition `json:"definitions"`
+	// These are only included with --debug
+	LookupMS *int64 `json:"lookup_ms,omitempty"`
+	CacheHit *bool  `json:"cache_hit,omitempty"`
+}
+
+// orderedCtxDefs returns the ctxDefinitions in the same order they're displayed in,
+// by dictionary and then by rank within each dictionary.
+func orderedCtxDefs(cDs []ctxDefinition, opts *options) []ctxDefinition {
+	index := make(map[string]int)
+	for i, dict := range dictOrder(byDictionary(cDs, false), opts) {
+		index[dict] = i
+	}
+	ret := make([]ctxDefinition, len(cDs))
+	copy(ret, cDs)
+	sort.SliceStable(ret, func(i, j int) bool {
+		if gi, gj := ret[i].groupName(), ret[j].groupName(); index[gi] != index[gj] {
+			return index[gi] < index[gj]
+		}
+		return ret[i].rank < ret[j].rank
+	})
+	return ret
+}
+
+// writeJSON writes the entry as a single line of JSON.
+func writeJSON(out io.Writer, e *entry, opts *options) error {
+	je := jsonEntry{
+		Word:        e.word,
+		FormOf:      e.formOf,
+		BaseForm:    e.lemma,
+		SourceURL:   e.url,
+		Frequency:   e.frequency,
+		Definitions: make([]jsonDefinition, 0, len(e.defs)),
+	}
+	if e.timing != nil {
+		ms := e.timing.took.Milliseconds()
+		je.LookupMS = &ms
+		je.CacheHit = &e.timing.cacheHit
+	}
+	for _, cD := range orderedCtxDefs(e.defs, opts) {
+		full, abbrev := posForms(cD.def.wordType)
+		je.Definitions = append(je.Definitions, jsonDefinition{
+			Dictionary:  cD.dict,
+			Rank:        cD.rank,
+			Homograph:   cD.homograph,
+			WordType:    cD.def.wordType,
+			POSFull:     full,
+			POSAbbrev:   abbrev,
+			Label:       cD.def.label,
+			Text:        cD.def.text,
+			Examples:    cD.def.examples,
+			SourceURL:   cD.sourceURL,
+			Attribution: cD.attribution,
+			AgreedBy:    cD.def.agreed,
+		})
+	}
+	return json.NewEncoder(out).Encode(je)
+}
+
+// jsonNotFound is how a word that wasn't found is represented in JSON output.
+type jsonNotFound struct {
+	Word        string   `json:"word"`
+	Error       string   `json:"error"` // Always "not_found"
+	Suggestions []string `json:"suggestions"`
+}
+
+// writeJSONNotFound writes a line of JSON for a word that wasn't found, where
+// err is the error from looking it up, with the similar words the source
+// suggested, if it's a notFoundError.
+func writeJSONNotFound(out io.Writer, w string, err error) error {
+	jn := jsonNotFound{Word: sanitizeText(w), Error: "not_found", Suggestions: make([]string, 0)}
+	var nf *notFoundError
+	if errors.As(err, &nf) {
+		for _, s := range nf.suggestions {
+			jn.Suggestions = append(jn.Suggestions, sanitizeText(s))
+		}
+	}
+	return json.NewEncoder(out).Encode(jn)
+}
+
+// jsonMinimalEntry is how an entry is represented with --json-minimal, just the
+// word and the text of its definitions.
+type jsonMinimalEntry struct {
+	Word        string   `json:"word"`
+	Definitions []string `json:"definitions"`
+}
+
+// writeJSONMinimal writes the entry as a single line of JSON, with only the text
+// of the definitions, in display order.
+func writeJSONMinimal(out io.Writer, e *entry, opts *options) error {
+	je := jsonMinimalEntry{Word: e.word, Definitions: make([]string, 0, len(e.defs))}
+	for _, cD := range orderedCtxDefs(e.defs, opts) {
+		je.Definitions = append(je.Definitions, cD.def.text)
+	}
+	return json.NewEncoder(out).Encode(je)
+}
+
+// csvHeader is the first row of CSV output, naming the columns written by writeCSV.
+var csvHeader = []string{"word", "dictionary", "rank", "word_type", "text", "source_url", "pos_full", "pos_abbrev"}
+
+// writeCSVHeader writes the header row for CSV output.
+func writeCSVHeader(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write(csvHeader)
+	w.Flush()
+	return w.Error()
+}
+
+// writeCSV writes a CSV row for every definition of the entry.
+func writeCSV(out io.Writer, e *entry, opts *options) error {
+	w := csv.NewWriter(out)
+	for _, cD := range orderedCtxDefs(e.defs, opts) {
+		full, abbrev := posForms(cD.def.wordType)
+		w.Write([]string{e.word, cD.dict, strconv.Itoa(cD.rank), cD.def.wordType, cD.def.text, cD.sourceURL, full, abbrev})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// ankiField makes s safe to use as a field of a tab separated Anki import file.
+func ankiField(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", "").Replace(s)
+}
+
+// writeAnki writes the entry as a line of an Anki import file, with the word as
+// the front of the card and the definitions as the back. The back is HTML, with
+// an ordered list of definitions for each group, since Anki renders HTML fields.
+// With --examples, each definition has a list of its examples under it.
+func writeAnki(out io.Writer, e *entry, opts *options) error {
+	var back strings.Builder
+	groups, _ := limitGroups(opts.grouper(e.defs, opts), opts)
+	for _, g := range groups {
+		defs, _ := headTail(g.defs, opts)
+		if len(defs) == 0 {
+			continue
+		}
+		if g.heading != "" {
+			back.WriteString("<b>" + html.EscapeString(g.heading) + "</b>")
+		}
+		back.WriteString("<ol>")
+		for _, d := range defs {
+			back.WriteString("<li>")
+			if d.wordType != "" && !opts.noWordType {
+				back.WriteString("<i>" + html.EscapeString(d.wordType) + "</i> ")
+			}
+			back.WriteString(html.EscapeString(d.text))
+			if opts.examples && len(d.examples) > 0 {
+				back.WriteString("<ul>")
+				for _, ex := range d.examples {
+					back.WriteString("<li>" + html.EscapeString(truncateExample(ex, opts)) + "</li>")
+				}
+				back.WriteString("</ul>")
+			}
+			back.WriteString("</li>")
+		}
+		back.WriteString("</ol>")
+	}
+	_, err := fmt.Fprintf(out, "%s\t%s\n", ankiField(e.word), ankiField(back.String()))
+	return err
+}
+
+// markdownEscaper escapes the characters that would be taken as markdown formatting in definition text.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, "#", `\#`)
+
+// writeGlossaryTerm writes the entry as a term of a --glossary document, with its
+// --glossary-defs best definitions, picked the same way as with --best.
+// In markdown each term is a list item, with its definitions in a numbered list
+// under it when there's more than one. In text the term is on its own line, with
+// its definitions indented under it.
+func writeGlossaryTerm(out io.Writer, e *entry, opts *options) error {
+	dicts := dictOrder(byDictionary(e.defs, opts.dedupWithinDict), opts)
+	best := bestDefinitions(e.defs, dicts, opts.glossaryDefs)
+	var b strings.Builder
+	if opts.glossary == "text" {
+		b.WriteString(e.word + "\n")
+		if len(best) == 0 {
+			b.WriteString("    (no definitions)\n")
+		}
+		for _, cD := range best {
+			b.WriteString("    ")
+			if cD.def.wordType != "" && !opts.noWordType {
+				b.WriteString(cD.def.wordType + "  ")
+			}
+			b.WriteString(cD.def.text + "\n")
+		}
+		b.WriteString("\n")
+		_, err := io.WriteString(out, b.String())
+		return err
+	}
+	def := func(cD ctxDefinition) string {
+		text := markdownEscaper.Replace(cD.def.text)
+		if cD.def.wordType != "" && !opts.noWordType {
+			return "*" + markdownEscaper.Replace(cD.def.wordType) + "* " + text
+		}
+		return text
+	}
+	term := "**" + markdownEscaper.Replace(e.word) + "**"
+	switch len(best) {
+	case 0:
+		b.WriteString("- " + term + ": (no definitions)\n")
+	case 1:
+		b.WriteString("- " + term + ": " + def(best[0]) + "\n")
+	default:
+		b.WriteString("- " + term + "\n")
+		for i, cD := range best {
+			fmt.Fprintf(&b, "  %d. %s\n", i+1, def(cD))
+		}
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
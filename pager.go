@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// pagerCommand returns the command to run for --pager, $PAGER or else less.
+func pagerCommand() []string {
+	if p := strings.Fields(os.Getenv("PAGER")); len(p) > 0 {
+		return p
+	}
+	return []string{"less"}
+}
+
+// writePaged writes the output to stdout, through the pager if it's more than
+// threshold lines long, so short output is printed like usual. If the pager
+// can't be run, the output is written to stdout directly instead.
+func writePaged(output []byte, threshold int, stdout io.Writer) error {
+	if bytes.Count(output, []byte("\n")) <= threshold {
+		_, err := stdout.Write(output)
+		return err
+	}
+	args := pagerCommand()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(output)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		// Show colors, and quit straight away if it fits on the screen after all, like git does
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		// The pager ran, so it showed the output, whatever its exit status was
+		return nil
+	}
+	debugLog.Printf("couldn't run the pager %q: %v", args[0], err)
+	_, err = stdout.Write(output)
+	return err
+}
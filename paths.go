@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appDir is the name of go-dict's directory inside the cache, config and data directories.
+const appDir = "go-dict"
+
+// baseDir returns a base directory following the XDG Base Directory specification.
+// If the environment variable xdgVar is set to an absolute path, that's used.
+// Otherwise it's the platform's usual directory: unixDir under the home directory
+// on Linux and the BSDs, darwinDir under the home directory on macOS, and the
+// directory in the winVar environment variable on Windows.
+func baseDir(xdgVar, unixDir, darwinDir, winVar string) (string, error) {
+	if dir := os.Getenv(xdgVar); filepath.IsAbs(dir) && runtime.GOOS != "windows" {
+		return dir, nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv(winVar); dir != "" {
+			return dir, nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, darwinDir), nil
+	}
+	return filepath.Join(home, unixDir), nil
+}
+
+// cacheDir returns the directory go-dict stores cached lookups in.
+func cacheDir() (string, error) {
+	dir, err := baseDir("XDG_CACHE_HOME", ".cache", "Library/Caches", "LocalAppData")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDir), nil
+}
+
+// configDir returns the directory go-dict's config file is in.
+func configDir() (string, error) {
+	dir, err := baseDir("XDG_CONFIG_HOME", ".config", "Library/Application Support", "AppData")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDir), nil
+}
+
+// dataDir returns the directory go-dict stores persistent data in, like history.
+func dataDir() (string, error) {
+	dir, err := baseDir("XDG_DATA_HOME", ".local/share", "Library/Application Support", "LocalAppData")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDir), nil
+}
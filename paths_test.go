@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestXDGPaths(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("the XDG variables are only used on Linux and the BSDs")
+	}
+	home := t.TempDir()
+	setenv(t, "HOME", home)
+	setenv(t, "XDG_CACHE_HOME", "/xdg/cache")
+	setenv(t, "XDG_CONFIG_HOME", "/xdg/config")
+	// Relative paths have to be ignored, by the spec
+	setenv(t, "XDG_DATA_HOME", "relative/data")
+
+	tests := []struct {
+		name string
+		got  func() (string, error)
+		want string
+	}{
+		{"cache", cacheDir, "/xdg/cache/go-dict"},
+		{"config", configDir, "/xdg/config/go-dict"},
+		{"data", dataDir, filepath.Join(home, ".local/share/go-dict")},
+		{"history", historyPath, filepath.Join(home, ".local/share/go-dict/history")},
+		{"config file", func() (string, error) { return defaultConfigPath(), nil }, "/xdg/config/go-dict/config.json"},
+	}
+	for _, tt := range tests {
+		got, err := tt.got()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s is %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	c, err := newCache(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.dir != "/xdg/cache/go-dict/entries" {
+		t.Errorf("cache entries are in %q, want /xdg/cache/go-dict/entries", c.dir)
+	}
+}
+
+func TestXDGPathsFallback(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("the XDG fallbacks are only used on Linux and the BSDs")
+	}
+	home := t.TempDir()
+	setenv(t, "HOME", home)
+	for _, v := range []string{"XDG_CACHE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME"} {
+		setenv(t, v, "")
+	}
+	tests := []struct {
+		name string
+		got  func() (string, error)
+		want string
+	}{
+		{"cache", cacheDir, filepath.Join(home, ".cache/go-dict")},
+		{"config", configDir, filepath.Join(home, ".config/go-dict")},
+		{"data", dataDir, filepath.Join(home, ".local/share/go-dict")},
+	}
+	for _, tt := range tests {
+		got, err := tt.got()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s is %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
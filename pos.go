@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// posAbbrevs maps the abbreviated parts of speech dictionaries use to their full names.
+var posAbbrevs = map[string]string{
+	"n.":      "noun",
+	"v.":      "verb",
+	"vt.":     "verb",
+	"vi.":     "verb",
+	"adj.":    "adjective",
+	"adv.":    "adverb",
+	"pron.":   "pronoun",
+	"prep.":   "preposition",
+	"conj.":   "conjunction",
+	"interj.": "interjection",
+	"art.":    "article",
+	"det.":    "determiner",
+	"abbr.":   "abbreviation",
+}
+
+// posShort maps the full names of parts of speech to the abbreviation used for
+// them, the other way around from posAbbrevs.
+var posShort = map[string]string{
+	"noun":         "n.",
+	"verb":         "v.",
+	"adjective":    "adj.",
+	"adverb":       "adv.",
+	"pronoun":      "pron.",
+	"preposition":  "prep.",
+	"conjunction":  "conj.",
+	"interjection": "interj.",
+	"article":      "art.",
+	"determiner":   "det.",
+	"abbreviation": "abbr.",
+}
+
+// posNames are the full names of the parts of speech normalizePOS reduces word types to.
+var posNames = map[string]bool{
+	"noun":         true,
+	"verb":         true,
+	"adjective":    true,
+	"adverb":       true,
+	"pronoun":      true,
+	"preposition":  true,
+	"conjunction":  true,
+	"interjection": true,
+	"article":      true,
+	"determiner":   true,
+	"abbreviation": true,
+	"prefix":       true,
+	"suffix":       true,
+	"idiom":        true,
+	"phrase":       true,
+}
+
+// normalizePOS returns the part of speech of a word type, so that abbreviated and
+// full forms are the same. For example "v. transitive", "transitive verb" and "verb"
+// are all "verb". Word types that aren't recognized are returned in lowercase,
+// and an empty one is "other".
+func normalizePOS(wordType string) string {
+	wT := strings.ToLower(strings.TrimSpace(wordType))
+	if wT == "" {
+		return "other"
+	}
+	for _, field := range strings.Fields(wT) {
+		if full, ok := posAbbrevs[field]; ok {
+			return full
+		}
+		if posNames[field] {
+			return field
+		}
+	}
+	return wT
+}
+
+// posForms returns the full name and the abbreviation of the part of speech of
+// a word type, for structured output, whether the dictionary abbreviated it or not.
+// Either is empty if it isn't known, like for word types normalizePOS doesn't
+// recognize, or parts of speech without an abbreviation.
+func posForms(wordType string) (full, abbrev string) {
+	pos := normalizePOS(wordType)
+	if !posNames[pos] {
+		return "", ""
+	}
+	return pos, posShort[pos]
+}
+
+// posCount is how many definitions of a word are of a part of speech.
+type posCount struct {
+	pos   string
+	count int
+}
+
+// posCounts returns how many definitions there are of each part of speech, most
+// common first, with ties in the order the parts of speech first appear.
+func posCounts(cDs []ctxDefinition, opts *options) []posCount {
+	counts := make([]posCount, 0)
+	index := make(map[string]int)
+	for _, cD := range orderedCtxDefs(cDs, opts) {
+		pos := normalizePOS(cD.def.wordType)
+		i, ok := index[pos]
+		if !ok {
+			i = len(counts)
+			index[pos] = i
+			counts = append(counts, posCount{pos: pos})
+		}
+		counts[i].count++
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].count > counts[j].count
+	})
+	return counts
+}
+
+// pprintPOSStats prints the word and how many definitions it has of each part of speech,
+// on a single line. For example "run: 15 verb, 8 noun, 2 adjective".
+func pprintPOSStats(out io.Writer, e *entry, c bool, opts *options) {
+	word := e.word
+	if c {
+		word = style("word").Render(word)
+	}
+	counts := posCounts(e.defs, opts)
+	if len(counts) == 0 {
+		fmt.Fprintln(out, word+": no definitions")
+		return
+	}
+	parts := make([]string, len(counts))
+	for i, pc := range counts {
+		parts[i] = fmt.Sprintf("%d %s", pc.count, pc.pos)
+	}
+	fmt.Fprintln(out, word+": "+strings.Join(parts, ", "))
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/peterh/liner"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// completionTimeout is how long completion waits for a source to suggest words.
+const completionTimeout = 2 * time.Second
+
+// maxCompletions is the most words suggested when completing.
+const maxCompletions = 20
+
+// collapseAt is how many definitions a word needs for its dictionaries to be
+// collapsed to just their headings at first, until they're expanded with :expand.
+const collapseAt = 10
+
+// numberedDef is a definition shown in interactive mode, along with its word.
+type numberedDef struct {
+	word string
+	def  definition
+}
+
+// repl is the interactive mode, where words are read from a prompt and looked up one at a time.
+type repl struct {
+	srcs    []Source
+	cache   *cache
+	names   map[string]string // Short dictionary names, for --trim-dictionary-names
+	opts    *options
+	history []string // Words looked up so far
+	// Definitions shown so far, numbered from 1 across the whole session so
+	// commands can refer to them
+	defs []numberedDef
+	// last is the last word looked up, before writeEntry changed it, so it can
+	// be shown again by :expand and :collapse
+	last      *entry
+	lastStart int      // The index in defs of the first definition of last
+	expandAll bool     // Whether all of last's groups are expanded
+	expanded  []string // Names of the groups of last that are expanded, see dictMatches
+	headings  []string // Headings of the groups of last, as they were shown
+	stdout    io.Writer
+	stderr    io.Writer
+}
+
+// historyPath returns the file the interactive mode's history is saved in.
+func historyPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history"), nil
+}
+
+// complete returns the suggested words for a partial word. Words are asked for from
+// the sources that can suggest them, and if none of them can, or they all fail, the
+// bundled dictionary and the words looked up before are used instead.
+func (r *repl) complete(prefix string) []string {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" || strings.HasPrefix(prefix, ":") {
+		return nil
+	}
+	seen := make(map[string]bool)
+	words := make([]string, 0)
+	add := func(ws []string) {
+		for _, w := range ws {
+			if !seen[w] && strings.HasPrefix(strings.ToLower(w), strings.ToLower(prefix)) {
+				seen[w] = true
+				words = append(words, w)
+			}
+		}
+	}
+	for _, src := range r.srcs {
+		cs, ok := src.(completerSource)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+		ws, err := cs.Complete(ctx, prefix)
+		cancel()
+		if err != nil {
+			debugLog.Printf("%s: completing %q failed: %v", src.Name(), prefix, err)
+			continue
+		}
+		add(ws)
+	}
+	if len(words) == 0 {
+		add(r.history)
+		add(bundledWords(prefix))
+	}
+	sort.Strings(words)
+	if len(words) > maxCompletions {
+		words = words[:maxCompletions]
+	}
+	return words
+}
+
+// lookup looks up the word and prints it, or the error.
+func (r *repl) lookup(w string) {
+	if !r.opts.caseSensitive {
+		w = strings.ToLower(w)
+	}
+	res := lookupWord(context.Background(), w, r.srcs, r.cache, r.opts)
+	if res.err != nil {
+		fmt.Fprintf(r.stderr, "go-dict: %s: %v\n", res.word, res.err)
+		return
+	}
+	r.history = append(r.history, w)
+	r.last = res.e
+	r.lastStart = len(r.defs)
+	r.expandAll = len(res.e.defs) < collapseAt
+	r.expanded = nil
+	r.show()
+}
+
+// show prints the last word looked up, with the groups that aren't expanded
+// collapsed to their headings. Showing it again numbers its definitions from
+// where they started the first time, so the numbers don't grow every time.
+func (r *repl) show() {
+	w := r.last.word
+	r.defs = r.defs[:r.lastStart]
+	r.opts.number = func(d *definition) int {
+		r.defs = append(r.defs, numberedDef{word: w, def: *d})
+		return len(r.defs)
+	}
+	r.headings = nil
+	r.opts.collapse = func(heading string) bool {
+		r.headings = append(r.headings, heading)
+		if r.expandAll {
+			return false
+		}
+		for _, name := range r.expanded {
+			if dictMatches(heading, name) {
+				return false
+			}
+		}
+		return true
+	}
+	var buf bytes.Buffer
+	writeEntry(&buf, r.last.clone(), r.names, r.opts)
+	r.stdout.Write(buf.Bytes())
+}
+
+// expand runs :expand and :collapse, changing which groups of the last word
+// are expanded and showing it again. The arg is part of the name of a group,
+// or "all", which is also what no arg means.
+func (r *repl) expand(cmd, arg string, expand bool) {
+	if r.last == nil {
+		fmt.Fprintf(r.stderr, "go-dict: nothing to %s, look up a word first\n", cmd)
+		return
+	}
+	if arg == "" || arg == "all" {
+		r.expandAll = expand
+		r.expanded = nil
+		r.show()
+		return
+	}
+	found := false
+	for _, heading := range r.headings {
+		if dictMatches(heading, arg) {
+			found = true
+		}
+	}
+	if !found {
+		fmt.Fprintf(r.stderr, "go-dict: %s has no dictionary %q\n", r.last.word, arg)
+		return
+	}
+	if expand {
+		r.expanded = append(r.expanded, arg)
+	} else {
+		if r.expandAll {
+			// Everything else stays expanded
+			r.expandAll = false
+			r.expanded = nil
+			for _, heading := range r.headings {
+				if !dictMatches(heading, arg) {
+					r.expanded = append(r.expanded, heading)
+				}
+			}
+		}
+		kept := r.expanded[:0]
+		for _, name := range r.expanded {
+			if !dictMatches(name, arg) {
+				kept = append(kept, name)
+			}
+		}
+		r.expanded = kept
+	}
+	r.show()
+}
+
+// numbered returns the definition with the number arg, as given to a command.
+func (r *repl) numbered(cmd, arg string) (*numberedDef, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("%s needs the number of a definition, like :%s 3", cmd, cmd)
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(r.defs) {
+		if len(r.defs) == 0 {
+			return nil, fmt.Errorf("no definition %s, look up a word first", arg)
+		}
+		return nil, fmt.Errorf("no definition %s, definitions are numbered 1 to %d", arg, len(r.defs))
+	}
+	return &r.defs[n-1], nil
+}
+
+// command runs a command, which is input starting with a colon, like ":syn 3".
+//
+// :example N shows the examples of definition N, and :syn N shows the synonyms
+// of the word definition N is for. :expand and :collapse show the dictionaries
+// of the last word looked up in full or as just their headings.
+func (r *repl) command(input string) {
+	fields := strings.Fields(strings.TrimPrefix(input, ":"))
+	if len(fields) == 0 {
+		fields = []string{""}
+	}
+	cmd, arg := fields[0], strings.Join(fields[1:], " ")
+	c := !r.opts.noColor
+	switch cmd {
+	case "example", "examples":
+		nd, err := r.numbered("example", arg)
+		if err != nil {
+			fmt.Fprintln(r.stderr, "go-dict:", err)
+			return
+		}
+		if len(nd.def.examples) == 0 {
+			fmt.Fprintf(r.stdout, "Definition %s of %s has no examples.\n", arg, nd.word)
+			return
+		}
+		opts := *r.opts
+		opts.number = nil
+		opts.examples = true
+		opts.explain = false
+		w := tabwriter.NewWriter(r.stdout, 0, 0, 2, ' ', 0)
+		pprintDef(w, &nd.def, true, c, &opts, typeColumn([]definition{nd.def}, &opts))
+		w.Flush()
+	case "syn", "synonyms":
+		nd, err := r.numbered("syn", arg)
+		if err != nil {
+			fmt.Fprintln(r.stderr, "go-dict:", err)
+			return
+		}
+		opts := *r.opts
+		opts.thesaurus = true
+		res := lookupWord(context.Background(), nd.word, r.srcs, r.cache, &opts)
+		if res.err != nil {
+			fmt.Fprintf(r.stderr, "go-dict: %s: %v\n", nd.word, res.err)
+			return
+		}
+		sanitizeEntry(res.e)
+		heading := "Synonyms of " + nd.word
+		if nd.def.wordType != "" {
+			heading += " (" + nd.def.wordType + ")"
+		}
+		// Synonyms of other word types are left out, like the ones of the verb for a noun,
+		// unless the source doesn't say what word type they're for
+		var syns []string
+		for _, rel := range res.e.related {
+			if !strings.Contains(strings.ToLower(rel.kind), "synonym") {
+				continue
+			}
+			if nd.def.wordType != "" && rel.wordType != "" && normalizePOS(rel.wordType) != normalizePOS(nd.def.wordType) {
+				continue
+			}
+			syns = append(syns, rel.words...)
+		}
+		if len(syns) == 0 {
+			fmt.Fprintf(r.stdout, "No synonyms found for %s.\n", nd.word)
+			return
+		}
+		if c {
+			heading = style("heading").Render(heading)
+		}
+		fmt.Fprintln(r.stdout, heading)
+		fmt.Fprintln(r.stdout, strings.Join(syns, ", "))
+	case "expand", "collapse":
+		r.expand(cmd, arg, cmd == "expand")
+	default:
+		fmt.Fprintf(r.stderr, "go-dict: unknown command %q, the commands are :example N, :syn N, :expand NAME and :collapse NAME\n", input)
+	}
+}
+
+// run reads words from the prompt and looks them up until the input ends,
+// Ctrl-C is pressed, or "exit" is entered. Tab completes a partial word.
+func (r *repl) run() int {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetCompleter(r.complete)
+	histPath, err := historyPath()
+	if err == nil {
+		if f, err := os.Open(histPath); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+	for {
+		input, err := line.Prompt("go-dict> ")
+		if err != nil {
+			// io.EOF or liner.ErrPromptAborted
+			break
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		if input == "exit" || input == "quit" {
+			break
+		}
+		line.AppendHistory(input)
+		if strings.HasPrefix(input, ":") {
+			r.command(input)
+			continue
+		}
+		r.lookup(input)
+	}
+	if histPath != "" {
+		if err := os.MkdirAll(filepath.Dir(histPath), 0755); err == nil {
+			if f, err := os.Create(histPath); err == nil {
+				line.WriteHistory(f)
+				f.Close()
+			}
+		}
+	}
+	return 0
+}
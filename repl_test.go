@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplNumbersStable(t *testing.T) {
+	ts := wordnikServer(t, map[string]string{"run": "run.html", "walk": "walk.html"})
+	isolate(t)
+	srcs, err := newSources("wordnik", ts.Client(), map[string]string{"wordnik": ts.URL + "/words/"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	r := &repl{srcs: srcs, opts: testOptions(t, "--no-cache"), stdout: &out, stderr: &out}
+	r.lookup("walk")
+	r.lookup("run")
+	if len(r.defs) != 5 {
+		t.Fatalf("%d definitions are numbered, want 5", len(r.defs))
+	}
+	// Showing the word again doesn't number its definitions again
+	r.command(":collapse all")
+	r.command(":expand all")
+	r.command(":expand all")
+	if len(r.defs) != 5 {
+		t.Errorf("%d definitions are numbered after showing run again, want 5", len(r.defs))
+	}
+	if nd := r.defs[4]; nd.word != "run" || nd.def.text != "A short trip." {
+		t.Errorf("definition 5 is %q for %s, want the last one of run", nd.def.text, nd.word)
+	}
+	if !strings.HasSuffix(out.String(), "5. noun  (informal) A short trip.\n\n") {
+		t.Errorf("the last definition shown isn't numbered 5:\n%s", out.String())
+	}
+}
+
+func TestReplSynonymsWordType(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture(t, "freedict/run.json"))
+	}))
+	defer ts.Close()
+	isolate(t)
+	srcs, err := newSources("freedict", ts.Client(), map[string]string{"freedict": ts.URL + "/"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	r := &repl{srcs: srcs, opts: testOptions(t, "--no-cache"), stdout: &out, stderr: &out}
+	r.lookup("run")
+	tests := []struct {
+		n    string
+		want string
+	}{
+		{"1", "Synonyms of run (noun)\njog\n"},
+		{"2", "Synonyms of run (verb)\ndash, sprint\n"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		r.command(":syn " + tt.n)
+		if got := out.String(); got != tt.want {
+			t.Errorf(":syn %s shows %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestReplSynonymsSanitized(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"word":"run","meanings":[{"partOfSpeech":"verb","definitions":[{"definition":"To move swiftly.","synonyms":["sprint\u001b[2J","\u202edash"]}]}]}]`))
+	}))
+	defer ts.Close()
+	isolate(t)
+	srcs, err := newSources("freedict", ts.Client(), map[string]string{"freedict": ts.URL + "/"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	r := &repl{srcs: srcs, opts: testOptions(t, "--no-cache"), stdout: &out, stderr: &out}
+	r.lookup("run")
+	out.Reset()
+	r.command(":syn 1")
+	if got, want := out.String(), "Synonyms of run (verb)\nsprint[2J, dash\n"; got != want {
+		t.Errorf(":syn 1 shows %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// reverseSource is a Source that can find words from a description of their
+// meaning, for --reverse.
+type reverseSource interface {
+	Source
+	// Reverse returns up to limit words whose meaning matches the query, most relevant first.
+	Reverse(ctx context.Context, query string, limit int) ([]string, error)
+}
+
+// reverseStopWords are the words left out of a --reverse query when searching
+// the bundled dictionary, since nearly every definition has them.
+var reverseStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "something": true, "someone": true, "that": true, "the": true, "to": true, "with": true,
+}
+
+// queryTerms returns the lowercased words of s, leaving out reverseStopWords.
+func queryTerms(s string) []string {
+	terms := make([]string, 0)
+	for _, t := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		if !reverseStopWords[t] {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// bundledReverse returns up to limit words from the bundled dictionary whose
+// definitions have the most of the query's words in them. Ties are in alphabetical
+// order, and words that are in the query themselves are left out.
+func bundledReverse(query string, limit int) []string {
+	terms := queryTerms(query)
+	inQuery := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		inQuery[t] = true
+	}
+	var words []string
+	scores := make(map[string]int)
+	bundledSearch("")
+	for _, line := range bundledLines {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 || inQuery[fields[0]] {
+			continue
+		}
+		text := make(map[string]bool)
+		for _, t := range queryTerms(fields[2]) {
+			text[t] = true
+		}
+		score := 0
+		for t := range inQuery {
+			if text[t] {
+				score++
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		if _, ok := scores[fields[0]]; !ok {
+			words = append(words, fields[0])
+		}
+		if score > scores[fields[0]] {
+			scores[fields[0]] = score
+		}
+	}
+	sort.SliceStable(words, func(i, j int) bool {
+		return scores[words[i]] > scores[words[j]]
+	})
+	if len(words) > limit {
+		words = words[:limit]
+	}
+	return words
+}
+
+// reverseLookup returns the words matching the query from the first source that
+// can do reverse lookups. Sources that fail are skipped for the next one.
+func reverseLookup(ctx context.Context, query string, limit int, srcs []Source) ([]string, error) {
+	var errs []string
+	for _, src := range srcs {
+		rs, ok := src.(reverseSource)
+		if !ok {
+			continue
+		}
+		words, err := rs.Reverse(ctx, query, limit)
+		if err == nil {
+			return words, nil
+		}
+		debugLog.Printf("%s: reverse lookup of %q failed: %v", src.Name(), query, err)
+		errs = append(errs, src.Name()+": "+err.Error())
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return nil, errors.New("none of the sources can look up words by their meaning, try --source wordnik-api or --offline")
+}
+
+// runReverse prints the words matching the --reverse query, most relevant first.
+// It returns the words, so they can be looked up with --define-candidates, and
+// the exit status if it failed, which is zero otherwise.
+func runReverse(srcs []Source, opts *options, stdout, stderr io.Writer) ([]string, int) {
+	ctx := context.Background()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	words, err := reverseLookup(ctx, opts.reverse, opts.reverseLimit, srcs)
+	if err != nil {
+		fmt.Fprintln(stderr, "go-dict:", err)
+		return nil, 1
+	}
+	if len(words) == 0 {
+		fmt.Fprintf(stderr, "go-dict: no words found meaning %q\n", opts.reverse)
+		return nil, 1
+	}
+	if opts.defineCandidates {
+		return words, 0
+	}
+	for _, w := range words {
+		fmt.Fprintln(stdout, sanitizeText(w))
+	}
+	return words, 0
+}
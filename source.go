@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// Source is somewhere words can be looked up.
+//
+// Sources must make their HTTP requests with doRequest, which retries requests
+// that are rate limited. Only requests that are safe to repeat are retried, see
+// isRetryable. So a source that uses a non-idempotent method like POST must set an
+// Idempotency-Key header if, and only if, sending it twice has no extra side effects.
+type Source interface {
+	// Name returns the identifier of the source, as used with --source.
+	Name() string
+	// Lookup returns an entry with the definitions for the word.
+	Lookup(ctx context.Context, w string) (*entry, error)
+}
+
+// thesaurusSource is a Source that can look up related words without definitions.
+type thesaurusSource interface {
+	Source
+	Thesaurus(ctx context.Context, w string) (*entry, error)
+}
+
+// completerSource is a Source that can suggest words starting with a prefix,
+// for completion in interactive mode.
+type completerSource interface {
+	Source
+	Complete(ctx context.Context, prefix string) ([]string, error)
+}
+
+// randomSource is a Source that can pick a random word, for --random.
+type randomSource interface {
+	Source
+	RandomWord(ctx context.Context) (string, error)
+}
+
+// wotdSource is a Source that has a word of the day, for --wotd.
+type wotdSource interface {
+	Source
+	WordOfTheDay(ctx context.Context) (string, error)
+}
+
+// frequencySource is a Source that knows how common words are, for --frequency.
+type frequencySource interface {
+	Source
+	// Frequency returns how many times the word is used in the source's corpus.
+	Frequency(ctx context.Context, w string) (int, error)
+}
+
+var (
+	// ErrWordNotFound is returned by sources when they don't have the word.
+	ErrWordNotFound = errors.New("word not found")
+	// ErrNetwork is wrapped by errors from sources that couldn't reach their server.
+	ErrNetwork = errors.New("couldn't connect")
+)
+
+// notFoundError is returned by sources that don't have the word but know of
+// similar ones. It wraps ErrWordNotFound.
+type notFoundError struct {
+	suggestions []string
+}
+
+func (e *notFoundError) Error() string {
+	if len(e.suggestions) == 0 {
+		return ErrWordNotFound.Error()
+	}
+	return ErrWordNotFound.Error() + ", did you mean: " + strings.Join(e.suggestions, ", ")
+}
+
+func (e *notFoundError) Unwrap() error {
+	return ErrWordNotFound
+}
+
+// isNetworkError reports whether err means the source couldn't be reached properly,
+// rather than the word being missing. Timeouts and rate limiting count too.
+func isNetworkError(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrRateLimited) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// errNoThesaurus is returned for sources that don't implement thesaurusSource.
+var errNoThesaurus = errors.New("source doesn't support thesaurus lookups")
+
+// baseURL returns the URL from urls for the source, or def if it's not there.
+// Overriding a source's URL is mainly useful for pointing it at a test server.
+func baseURL(urls map[string]string, source, def string) string {
+	if u, ok := urls[source]; ok {
+		return u
+	}
+	return def
+}
+
+// sourceInfo describes a source, for --list-sources.
+type sourceInfo struct {
+	name        string
+	description string
+	apiKey      string // What the source's API key is stored under, see apiKey, or empty if it doesn't need one
+}
+
+// sourceInfos are the sources newSource knows, in the order they're listed in.
+var sourceInfos = []sourceInfo{
+	{"wordnik", "Scrapes the wordnik website", ""},
+	{"wordnik-api", "The Wordnik API, faster and less likely to be rate limited", "wordnik"},
+	{"freedict", "The Free Dictionary API, with pronunciations and related words", ""},
+	{"offline", "The bundled dictionary of common words, which doesn't need the network", ""},
+}
+
+// sourceNames returns the names of all the sources, as used with --source.
+func sourceNames() []string {
+	names := make([]string, len(sourceInfos))
+	for i, si := range sourceInfos {
+		names[i] = si.name
+	}
+	return names
+}
+
+// listSources writes each source to out for --list-sources, with its description
+// and whether it can be used, which it can't if it needs an API key that isn't set.
+func listSources(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, si := range sourceInfos {
+		status := "ready"
+		if si.apiKey != "" {
+			if _, err := apiKey(si.apiKey); err != nil {
+				status = "needs an API key, set " + envName(si.apiKey+"-api-key")
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", si.name, si.description, status)
+	}
+	w.Flush()
+}
+
+// newSource returns the source with the provided name.
+// urls can override the default URL of sources by name, and can be nil.
+// acceptLanguage is the Accept-Language header sources that serve web pages send,
+// or empty to not send one.
+func newSource(name string, client *http.Client, urls map[string]string, acceptLanguage string) (Source, error) {
+	switch name {
+	case "wordnik":
+		client = withHeader(client, "Accept-Language", acceptLanguage)
+		return &wordnik{client: client, baseURL: baseURL(urls, name, wordnikURL)}, nil
+	case "wordnik-api":
+		return &wordnikAPI{
+			client:  client,
+			baseURL: baseURL(urls, name, wordnikAPIURL),
+			pageURL: baseURL(urls, "wordnik", wordnikURL),
+		}, nil
+	case "freedict":
+		return &freedict{client: client, baseURL: baseURL(urls, name, freedictURL)}, nil
+	case "offline":
+		return &offline{}, nil
+	}
+	return nil, fmt.Errorf("unknown source %q, the sources are: %s", name, strings.Join(sourceNames(), ", "))
+}
+
+// newSources returns the sources named in a comma separated list, see newSource.
+func newSources(names string, client *http.Client, urls map[string]string, acceptLanguage string) ([]Source, error) {
+	srcs := make([]Source, 0)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		src, err := newSource(name, client, urls, acceptLanguage)
+		if err != nil {
+			return nil, err
+		}
+		srcs = append(srcs, src)
+	}
+	if len(srcs) == 0 {
+		return nil, errors.New("no sources provided")
+	}
+	return srcs, nil
+}
+
+// sourceLookup looks up the word in a single source, using a thesaurus lookup if thesaurus is true.
+// If c isn't nil, the entry is taken from the cache when it's there, and added to it when it isn't.
+func sourceLookup(ctx context.Context, src Source, w string, thesaurus bool, c *cache) (*entry, error) {
+	if _, ok := src.(*offline); ok {
+		// The bundled dictionary is already on disk
+		c = nil
+	}
+	if c != nil {
+		if e, ok := c.get(src.Name(), w, thesaurus); ok {
+			return e, nil
+		}
+		if suggestions, ok := c.notFound(src.Name(), w, thesaurus); ok {
+			if len(suggestions) > 0 {
+				return nil, &notFoundError{suggestions: suggestions}
+			}
+			return nil, ErrWordNotFound
+		}
+	}
+	var e *entry
+	var err error
+	if !thesaurus {
+		e, err = src.Lookup(ctx, w)
+	} else if ts, ok := src.(thesaurusSource); ok {
+		e, err = ts.Thesaurus(ctx, w)
+	} else {
+		return nil, errNoThesaurus
+	}
+	if c != nil && (err == nil || errors.Is(err, ErrWordNotFound)) {
+		var cErr error
+		if err == nil {
+			cErr = c.put(src.Name(), w, thesaurus, e)
+		} else {
+			var nfErr *notFoundError
+			var suggestions []string
+			if errors.As(err, &nfErr) {
+				suggestions = nfErr.suggestions
+			}
+			cErr = c.putNotFound(src.Name(), w, thesaurus, suggestions)
+		}
+		if cErr != nil {
+			debugLog.Printf("couldn't cache %q from %s: %v", w, src.Name(), cErr)
+		}
+	}
+	return e, err
+}
+
+// allCached reports whether the word is in the cache for every source,
+// so looking it up won't need the network.
+func allCached(w string, srcs []Source, thesaurus bool, c *cache) bool {
+	for _, src := range srcs {
+		if _, ok := src.(*offline); ok {
+			continue
+		}
+		if _, ok := c.get(src.Name(), w, thesaurus); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupAll looks up the word in all the sources concurrently and merges the results.
+// The entry of the first source in the list that succeeds is used as the base,
+// and the definitions and related words of the other sources are added to it in order,
+// so the result doesn't depend on which source responds first.
+// A source that fails is left out, an error is only returned if all of them fail.
+// If agreement is true, definitions are marked with the sources that agree on them,
+// see markAgreement. The cache is used if c isn't nil, see sourceLookup.
+func lookupAll(ctx context.Context, w string, srcs []Source, thesaurus, agreement bool, c *cache) (*entry, error) {
+	if len(srcs) == 1 {
+		// No need for goroutines
+		return sourceLookup(ctx, srcs[0], w, thesaurus, c)
+	}
+	type result struct {
+		e   *entry
+		err error
+	}
+	results := make([]chan result, len(srcs))
+	for i, src := range srcs {
+		results[i] = make(chan result, 1)
+		go func(ch chan result, src Source) {
+			e, err := sourceLookup(ctx, src, w, thesaurus, c)
+			ch <- result{e, err}
+		}(results[i], src)
+	}
+
+	var merged *entry
+	var firstErr error
+	var from []string // The source of each merged definition
+	for i, ch := range results {
+		r := <-ch
+		if r.err != nil {
+			debugLog.Printf("%s: lookup of %q failed: %v", srcs[i].Name(), w, r.err)
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		for range r.e.defs {
+			from = append(from, srcs[i].Name())
+		}
+		if merged == nil {
+			merged = r.e
+			continue
+		}
+		merged.defs = append(merged.defs, r.e.defs...)
+		merged.related = append(merged.related, r.e.related...)
+	}
+	if merged == nil {
+		return nil, firstErr
+	}
+	if agreement {
+		markAgreement(merged.defs, from)
+	}
+	return merged, nil
+}
+
+// markAgreement records on each definition the sources that have a definition
+// with the same text, once it's normalized by agreementKey, where from is the
+// source of each definition. Definitions only one source has are left alone.
+func markAgreement(cDs []ctxDefinition, from []string) {
+	bySource := make(map[string][]string)
+	seen := make(map[[2]string]bool)
+	for i := range cDs {
+		key := agreementKey(cDs[i].def.text)
+		if !seen[[2]string{key, from[i]}] {
+			seen[[2]string{key, from[i]}] = true
+			bySource[key] = append(bySource[key], from[i])
+		}
+	}
+	for i := range cDs {
+		if names := bySource[agreementKey(cDs[i].def.text)]; len(names) > 1 {
+			cDs[i].def.agreed = names
+		}
+	}
+}
+
+// agreementKey normalizes a definition's text so definitions that only differ
+// in case, punctuation or spacing are the same.
+func agreementKey(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// lookupFirst looks up the word in each source in turn, returning the entry from
+// the first one that has it, for --source-order. A source that doesn't have the
+// word, or has no definitions for it, moves on to the next one, and so does one
+// that fails, unless stopOnNetworkError is true and it was a network error.
+// If no source has the word, the first error is returned.
+func lookupFirst(ctx context.Context, w string, srcs []Source, thesaurus, stopOnNetworkError bool, c *cache) (*entry, error) {
+	var firstErr error
+	for _, src := range srcs {
+		e, err := sourceLookup(ctx, src, w, thesaurus, c)
+		if err == nil && (len(e.defs) > 0 || (thesaurus && len(e.related) > 0)) {
+			return e, nil
+		}
+		if err == nil {
+			err = ErrWordNotFound
+		}
+		debugLog.Printf("%s: lookup of %q failed, trying the next source: %v", src.Name(), w, err)
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil || (stopOnNetworkError && isNetworkError(err)) {
+			return nil, err
+		}
+	}
+	return nil, firstErr
+}
+
+// existsSource is a Source that can check whether it has a word more cheaply
+// than looking it up.
+type existsSource interface {
+	Source
+	Exists(ctx context.Context, w string) (bool, error)
+}
+
+// sourceExists reports whether the source has definitions for the word.
+// If c isn't nil, cached entries and not found results are used first, and
+// words the source doesn't have are added to it.
+func sourceExists(ctx context.Context, src Source, w string, c *cache) (bool, error) {
+	if _, ok := src.(*offline); ok {
+		c = nil
+	}
+	if c != nil {
+		if e, ok := c.get(src.Name(), w, false); ok {
+			return len(e.defs) > 0, nil
+		}
+		if _, ok := c.notFound(src.Name(), w, false); ok {
+			return false, nil
+		}
+	}
+	if es, ok := src.(existsSource); ok {
+		ok, err := es.Exists(ctx, w)
+		if err == nil && !ok && c != nil {
+			if cErr := c.putNotFound(src.Name(), w, false, nil); cErr != nil {
+				debugLog.Printf("couldn't cache %q from %s: %v", w, src.Name(), cErr)
+			}
+		}
+		return ok, err
+	}
+	e, err := sourceLookup(ctx, src, w, false, c)
+	if errors.Is(err, ErrWordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(e.defs) > 0, nil
+}
+
+// checkAll returns nil if any of the sources has the word, and ErrWordNotFound
+// if none of them do. The sources are checked in order, stopping at the first one
+// that has it. If a source fails the next one is tried, and the first error is
+// returned if none of the others have the word either. The cache is used if
+// c isn't nil, see sourceExists.
+func checkAll(ctx context.Context, w string, srcs []Source, c *cache) error {
+	var firstErr error
+	for _, src := range srcs {
+		ok, err := sourceExists(ctx, src, w, c)
+		if err != nil {
+			debugLog.Printf("%s: checking %q failed: %v", src.Name(), w, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if ok {
+			return nil
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return ErrWordNotFound
+}
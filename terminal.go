@@ -0,0 +1,48 @@
+package main
+
+import (
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strconv"
+)
+
+// defaultWidth is the terminal width assumed when it can't be found out.
+const defaultWidth = 80
+
+// defaultHeight is the terminal height assumed when it can't be found out.
+const defaultHeight = 24
+
+// isTerminal reports whether out writes to a terminal.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// terminalWidth returns the width of the terminal out writes to. If out isn't a
+// terminal, the COLUMNS environment variable is used, and then defaultWidth.
+func terminalWidth(out io.Writer) int {
+	if isTerminal(out) {
+		if w, _, err := term.GetSize(int(out.(*os.File).Fd())); err == nil && w > 0 {
+			return w
+		}
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return defaultWidth
+}
+
+// terminalHeight returns the height of the terminal out writes to. If out isn't a
+// terminal, the LINES environment variable is used, and then defaultHeight.
+func terminalHeight(out io.Writer) int {
+	if isTerminal(out) {
+		if _, h, err := term.GetSize(int(out.(*os.File).Fd())); err == nil && h > 0 {
+			return h
+		}
+	}
+	if h, err := strconv.Atoi(os.Getenv("LINES")); err == nil && h > 0 {
+		return h
+	}
+	return defaultHeight
+}
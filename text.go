@@ -0,0 +1,190 @@
+package main
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// capitalize returns s with its first letter in upper case. It works on runes,
+// so it's safe for empty strings and ones starting with a multi-byte letter, like "écu".
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// cleanText removes soft hyphens from scraped text, and decodes any HTML
+// entities left in it, like an "&amp;" that was escaped twice.
+func cleanText(s string) string {
+	s = strings.Replace(s, "\u00ad", "", -1)
+	if strings.Contains(s, "&") {
+		s = html.UnescapeString(s)
+	}
+	return s
+}
+
+// isBidiControl reports whether r is one of the Unicode characters that change
+// the direction of text, which can make a terminal show text in a different
+// order than it really is in.
+func isBidiControl(r rune) bool {
+	switch {
+	case r == '\u061c', r == '\u200e', r == '\u200f':
+		return true
+	case r >= '\u202a' && r <= '\u202e':
+		return true
+	case r >= '\u2066' && r <= '\u2069':
+		return true
+	}
+	return false
+}
+
+// sanitizeText removes control characters and bidirectional controls from s, so
+// text from a source can't move the cursor, change colors, or reorder what's shown.
+// Tabs and newlines become spaces, since definitions are shown on a single line.
+func sanitizeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return ' '
+		case unicode.IsControl(r), isBidiControl(r):
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// sanitizeEntry runs sanitizeText on all the text in an entry.
+func sanitizeEntry(e *entry) {
+	e.word = sanitizeText(e.word)
+	for i := range e.defs {
+		cD := &e.defs[i]
+		cD.dict = sanitizeText(cD.dict)
+		cD.attribution = sanitizeText(cD.attribution)
+		cD.def.wordType = sanitizeText(cD.def.wordType)
+		cD.def.label = sanitizeText(cD.def.label)
+		cD.def.text = sanitizeText(cD.def.text)
+		for j := range cD.def.examples {
+			cD.def.examples[j] = sanitizeText(cD.def.examples[j])
+		}
+	}
+	for i := range e.related {
+		e.related[i].kind = sanitizeText(e.related[i].kind)
+		e.related[i].wordType = sanitizeText(e.related[i].wordType)
+		for j := range e.related[i].words {
+			e.related[i].words[j] = sanitizeText(e.related[i].words[j])
+		}
+	}
+	for h, pron := range e.pronunciations {
+		e.pronunciations[h] = sanitizeText(pron)
+	}
+	e.formOf = sanitizeText(e.formOf)
+	e.lemma = sanitizeText(e.lemma)
+}
+
+// escapeLen returns the length of the ANSI escape sequence at the start of s,
+// or zero if s doesn't start with one.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != '\x1b' || s[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		// The final byte of a CSI sequence is in the range @ to ~
+		if s[i] >= '@' && s[i] <= '~' {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// stripEscapes returns s without any ANSI escape sequences, so colored output
+// can be used as plain text.
+func stripEscapes(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if l := escapeLen(s[i:]); l > 0 {
+			i += l
+			continue
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// visibleWidth returns how many characters of s are displayed, ignoring ANSI escape sequences.
+func visibleWidth(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if l := escapeLen(s[i:]); l > 0 {
+			i += l
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return n
+}
+
+// truncateVisible shortens s so it displays as at most n characters, ending it with
+// an ellipsis if anything was cut off. ANSI escape sequences aren't counted and
+// are never cut in half. If s had any, a reset is added after the ellipsis so the
+// colors don't bleed into whatever comes next.
+func truncateVisible(s string, n int) string {
+	if visibleWidth(s) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	hasEscapes := false
+	shown := 0
+	for i := 0; i < len(s) && shown < n-1; {
+		if l := escapeLen(s[i:]); l > 0 {
+			b.WriteString(s[i : i+l])
+			hasEscapes = true
+			i += l
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(s[i : i+size])
+		i += size
+		shown++
+	}
+	b.WriteString("…")
+	if hasEscapes {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// wrapText splits s into lines at spaces, so the first line is at most first
+// characters wide and the rest are at most rest wide. Words longer than a line
+// are left whole rather than broken. s must not contain ANSI escape sequences.
+func wrapText(s string, first, rest int) []string {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return []string{s}
+	}
+	lines := make([]string, 0, 1)
+	line := words[0]
+	width := first
+	for _, word := range words[1:] {
+		if utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line)
+			line = word
+			width = rest
+			continue
+		}
+		line += " " + word
+	}
+	return append(lines, line)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"dic­tion­ary", "dictionary"},
+		{"R&amp;D", "R&D"},
+		{"R&D", "R&D"},
+		{"&lt;b&gt;", "<b>"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := cleanText(tt.in); got != tt.want {
+			t.Errorf("cleanText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestVisibleWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"plain", 5},
+		{"\x1b[31mred\x1b[0m", 3},
+		{"\x1b[1;38;5;208mécu\x1b[0m and more", 12},
+		{"\x1b[", 0},
+	}
+	for _, tt := range tests {
+		if got := visibleWidth(tt.in); got != tt.want {
+			t.Errorf("visibleWidth(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// escapesWhole reports whether every ANSI escape sequence in s is complete.
+func escapesWhole(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\x1b' {
+			continue
+		}
+		l := escapeLen(s[i:])
+		if l < 3 || s[i+l-1] < '@' || s[i+l-1] > '~' {
+			return false
+		}
+		i += l - 1
+	}
+	return true
+}
+
+func TestTruncateVisible(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"too long", 5, "too …"},
+		{"anything", 0, ""},
+		// Escapes aren't counted, and the colors are reset after the ellipsis
+		{"\x1b[31mred text\x1b[0m", 8, "\x1b[31mred text\x1b[0m"},
+		{"\x1b[31mred text\x1b[0m", 5, "\x1b[31mred …\x1b[0m"},
+		// An escape right where it's cut is left out, rather than cut in half
+		{"abcd\x1b[1;32mefgh\x1b[0m", 5, "abcd…"},
+		{"abcd\x1b[1;32mefgh\x1b[0m", 6, "abcd\x1b[1;32me…\x1b[0m"},
+		{"\x1b[1mécu\x1b[0m noir", 3, "\x1b[1méc…\x1b[0m"},
+	}
+	for _, tt := range tests {
+		got := truncateVisible(tt.in, tt.n)
+		if got != tt.want {
+			t.Errorf("truncateVisible(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+		if w := visibleWidth(got); w > tt.n {
+			t.Errorf("truncateVisible(%q, %d) is %d wide", tt.in, tt.n, w)
+		}
+		if !escapesWhole(got) {
+			t.Errorf("truncateVisible(%q, %d) = %q cut an escape in half", tt.in, tt.n, got)
+		}
+	}
+}
+
+func TestColoredSummaryTruncation(t *testing.T) {
+	ts := wordnikServer(t, map[string]string{"run": "run.html"})
+	isolate(t)
+	var stdout, stderr bytes.Buffer
+	args := []string{"--no-cache", "--color", "always", "--show-rank-zero-only", "--summary-width", "30", "run"}
+	if code := run(args, map[string]string{"wordnik": ts.URL + "/words/"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr.String())
+	}
+	line := strings.TrimRight(stdout.String(), "\n")
+	if !strings.Contains(line, "\x1b[") {
+		t.Fatalf("%q isn't colored", line)
+	}
+	def := line[strings.Index(line, "  ")+2:]
+	if w := visibleWidth(def); w != 30 {
+		t.Errorf("%q is %d wide, want 30", def, w)
+	}
+	if !strings.HasSuffix(def, "…") {
+		t.Errorf("%q doesn't end with an ellipsis", def)
+	}
+	if !escapesWhole(line) {
+		t.Errorf("%q has an escape cut in half", line)
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"right\u202eto left", "rightto left"},
+		{"\u2066isolated\u2069", "isolated"},
+		{"marks\u200e\u200f\u061c", "marks"},
+		{"\x1b[31mred\x1b[0m", "[31mred[0m"},
+		{"bell\a", "bell"},
+		{"tab\tand\nnewline", "tab and newline"},
+		{"écu ½ 日本", "écu ½ 日本"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeText(tt.in); got != tt.want {
+			t.Errorf("sanitizeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeWordnik(t *testing.T) {
+	ts := wordnikServer(t, map[string]string{"exe": "bidi.html"})
+	stdout, stderr, code := runWordnik(t, ts, "exe")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	want := "exe\nWiktionary\nn.  A file named gpj.exe, which looks like an image.\n" +
+		"n.  Text with a bell and an escape[31m in it.\n\n"
+	if stdout != want {
+		t.Errorf("stdout is %q, want %q", stdout, want)
+	}
+}
+
+func TestSanitizeRandomWord(t *testing.T) {
+	// The word --random looks up comes from the URL wordnik redirects to
+	mux := http.NewServeMux()
+	mux.HandleFunc("/randoword", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/words/walk%1B%5B2J%E2%80%AE", http.StatusFound)
+	})
+	mux.HandleFunc("/words/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture(t, "wordnik/walk.html"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--random"}, "walk[2j\nWiktionary\n"},
+		{[]string{"--random", "--json"}, `"word":"walk[2j"`},
+	} {
+		stdout, stderr, code := runWordnik(t, ts, tt.args...)
+		if code != 0 {
+			t.Fatalf("%v: exit status %d: %s", tt.args, code, stderr)
+		}
+		if !strings.Contains(stdout, tt.want) {
+			t.Errorf("%v: stdout is %q, want it to have %q", tt.args, stdout, tt.want)
+		}
+	}
+}
+
+func TestCapitalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"a", "A"},
+		{"é", "É"},
+		{"ab", "Ab"},
+		{"écu", "Écu"},
+		{"1", "1"},
+		{"\xff", "\xff"},
+	}
+	for _, tt := range tests {
+		if got := capitalize(tt.in); got != tt.want {
+			t.Errorf("capitalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/gookit/color.v1"
+	"io"
+	"sort"
+	"strings"
+)
+
+// defaultTheme is the style of each part of the output, by the name used for it
+// in the "theme" of the config file.
+var defaultTheme = map[string]color.Style{
+	"banner":           color.New(color.BgRed, color.White), // The word at the top of its entry
+	"heading":          color.New(color.BgGray),             // Dictionary and other group headings
+	"word":             color.New(color.OpBold),             // The word in one line modes
+	"word_type":        color.New(color.OpItalic),
+	"first_word_type":  color.New(color.OpItalic, color.OpBold), // Word type of a group's first definition
+	"first_definition": color.New(color.Cyan),
+	"example":          color.New(color.Gray, color.OpItalic),
+	"label":            color.New(color.Gray, color.OpItalic), // Usage labels like archaic or slang
+	"note":             color.New(color.Gray),                 // Dictionary names in --best, --explain, counts of hidden definitions, etc.
+	"form_of":          color.New(color.OpItalic),
+	"relation":         color.New(color.BgGray), // Kinds of related words with --thesaurus
+	"stress":           color.New(color.OpBold), // Stressed syllables of pronunciations
+}
+
+// themePresets are the themes that can be picked with "theme_preset" in the
+// config file, as the styles they change from defaultTheme.
+var themePresets = map[string]map[string]color.Style{
+	"default": {},
+	// For terminals with a light background, where gray and cyan are hard to read
+	"light": {
+		"banner":           color.New(color.BgBlue, color.White),
+		"heading":          color.New(color.OpBold, color.OpUnderscore),
+		"first_definition": color.New(color.Blue),
+		"example":          color.New(color.OpItalic),
+		"label":            color.New(color.Magenta, color.OpItalic),
+		"note":             color.New(color.OpItalic),
+		"relation":         color.New(color.OpBold, color.OpUnderscore),
+	},
+	// Without any colors, only bold, italic and the like
+	"mono": {
+		"banner":           color.New(color.OpReverse, color.OpBold),
+		"heading":          color.New(color.OpUnderscore),
+		"first_definition": color.New(color.OpBold),
+		"example":          color.New(color.OpItalic),
+		"label":            color.New(color.OpFuzzy, color.OpItalic),
+		"note":             color.New(color.OpFuzzy),
+		"relation":         color.New(color.OpUnderscore),
+	},
+}
+
+// presetNames returns the names of the themePresets, with default first and the rest alphabetically.
+func presetNames() []string {
+	names := make([]string, 0, len(themePresets))
+	for name := range themePresets {
+		if name != "default" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{"default"}, names...)
+}
+
+// presetTheme returns the styles of the preset theme with the provided name.
+func presetTheme(name string) (map[string]color.Style, error) {
+	preset, ok := themePresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme preset %q, valid presets are: %s", name, strings.Join(presetNames(), ", "))
+	}
+	styles := make(map[string]color.Style, len(defaultTheme))
+	for name, s := range defaultTheme {
+		styles[name] = s
+	}
+	for name, s := range preset {
+		styles[name] = s
+	}
+	return styles, nil
+}
+
+// theme is the style of each part of the output in use. run sets it to the theme
+// parsed from the config file, or defaultTheme if the config file doesn't have one.
+var theme = defaultTheme
+
+// style returns the style in use for the part of the output with the provided name.
+func style(name string) color.Style {
+	return theme[name]
+}
+
+// themeColors returns the color names that can be used in a theme, mapped to their colors.
+// Foreground colors use the gookit color names, like "red" or "lightBlue", background colors
+// have a "bg_" prefix, like "bg_red", and there are options like "bold" and "italic".
+func themeColors() map[string]color.Color {
+	m := make(map[string]color.Color)
+	for name, c := range color.FgColors {
+		m[name] = c
+	}
+	for name, c := range color.ExFgColors {
+		m[name] = c
+	}
+	for name, c := range color.BgColors {
+		m["bg_"+name] = c
+	}
+	for name, c := range color.ExBgColors {
+		m["bg_"+name] = c
+	}
+	for name, c := range color.Options {
+		if name != "reset" {
+			m[name] = c
+		}
+	}
+	m["gray"] = color.Gray
+	m["bg_gray"] = color.BgGray
+	return m
+}
+
+// parseTheme returns the styles for a theme from the config file, which maps
+// parts of the output to space separated color names, like "bold bg_blue".
+// Parts that aren't in it keep their styles from base, which isn't changed.
+// Unknown parts or colors are errors that list the valid ones.
+func parseTheme(conf map[string]string, base map[string]color.Style) (map[string]color.Style, error) {
+	colors := themeColors()
+	styles := make(map[string]color.Style, len(base))
+	for name, s := range base {
+		styles[name] = s
+	}
+	for name, value := range conf {
+		if _, ok := defaultTheme[name]; !ok {
+			parts := make([]string, 0, len(defaultTheme))
+			for part := range defaultTheme {
+				parts = append(parts, part)
+			}
+			sort.Strings(parts)
+			return nil, fmt.Errorf("unknown theme part %q, valid parts are: %s", name, strings.Join(parts, ", "))
+		}
+		s := color.New()
+		for _, field := range strings.Fields(value) {
+			c, ok := colors[field]
+			if !ok {
+				names := make([]string, 0, len(colors))
+				for n := range colors {
+					names = append(names, n)
+				}
+				sort.Strings(names)
+				return nil, fmt.Errorf("unknown color %q for theme part %q, valid colors are: %s", field, name, strings.Join(names, ", "))
+			}
+			s = append(s, c)
+		}
+		styles[name] = s
+	}
+	return styles, nil
+}
+
+// previewEntry is the sample entry shown in each theme by --theme-preview.
+var previewEntry = entry{
+	word:           "serendipity",
+	pronunciations: map[int]string{0: "/ˌsɛɹ.ənˈdɪp.ɪ.ti/"},
+	defs: []ctxDefinition{
+		{dict: "Sample Dictionary", rank: 0, def: definition{
+			wordType: "noun",
+			text:     "The faculty of making fortunate discoveries by accident.",
+			examples: []string{"It was pure serendipity that we met."},
+		}},
+		{dict: "Sample Dictionary", rank: 1, def: definition{
+			wordType: "noun",
+			label:    "archaic",
+			text:     "A fortunate discovery made by accident.",
+		}},
+		{dict: "Another Dictionary", rank: 0, def: definition{
+			wordType: "noun",
+			text:     "Good luck in finding valuable things unintentionally.",
+		}},
+	},
+}
+
+// previewThemes writes previewEntry in each of the themePresets, for --theme-preview.
+// Colors are always used, since showing them is the point.
+func previewThemes(out io.Writer, opts *options) {
+	preview := *opts
+	preview.examples = true
+	defer func(t map[string]color.Style) { theme = t }(theme)
+	for _, name := range presetNames() {
+		theme, _ = presetTheme(name)
+		fmt.Fprintf(out, "Theme preset %q:\n\n", name)
+		e := previewEntry
+		e.parsed = len(e.defs)
+		pprintEntry(out, &e, true, &preview)
+	}
+}
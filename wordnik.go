@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// wordnikURL is the default URL of wordnik's word pages, the word is added to the end.
+const wordnikURL = "https://www.wordnik.com/words/"
+
+// stripWordType removes the word type wT from the start of a definition's text.
+// Whitespace is normalized first, so differences in spacing don't matter. If the
+// text doesn't actually start with the word type, it's returned without removing anything.
+func stripWordType(text, wT string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	wT = strings.Join(strings.Fields(wT), " ")
+	if wT == "" || !strings.HasPrefix(text, wT) {
+		return text
+	}
+	return strings.TrimSpace(text[len(wT):])
+}
+
+// wordnikPage returns the URL of the page for the word, which can be a phrase like
+// "kick the bucket", so it's escaped. Runs of spaces are treated as one.
+func wordnikPage(base, w string) string {
+	return base + url.PathEscape(strings.Join(strings.Fields(w), " "))
+}
+
+// wordnikWordType splits the word type off the start of a definition on a wordnik
+// page, returning it and the rest of the text. The word type is made of the first
+// abbr element, like "v.", and the first i element, like "transitive", but either
+// can be missing, and each is only part of it if it's at the start of what's left,
+// so italics later in the text aren't taken for the word type.
+func wordnikWordType(def *goquery.Selection) (string, string) {
+	text := strings.Join(strings.Fields(cleanText(def.Text())), " ")
+	parts := make([]string, 0, 2)
+	for _, sel := range []string{"abbr", "i"} {
+		part := strings.Join(strings.Fields(cleanText(def.Find(sel).First().Text())), " ")
+		if part == "" {
+			continue
+		}
+		if rest := stripWordType(text, part); rest != text {
+			parts = append(parts, part)
+			text = rest
+		}
+	}
+	return strings.Join(parts, " "), text
+}
+
+// wordnikGet requests a page from wordnik.com, returning the response if it was successful.
+// The caller must close the response body.
+func wordnikGet(ctx context.Context, u string, client *http.Client) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/74.0.3729.169 Safari/537.36")
+	resp, err := doRequest(ctx, client, req)
+	if errors.Is(err, ErrRateLimited) || (err != nil && ctx.Err() != nil) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w to wordnik: %s", ErrNetwork, connectProblem(err))
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrWordNotFound
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, errors.New("200 not returned, likely a non-word like '../test' was passed")
+	}
+	return resp, nil
+}
+
+// wordnikFetch downloads and parses the wordnik.com page for the provided word.
+// base is the URL of the word pages, usually wordnikURL.
+func wordnikFetch(ctx context.Context, base, w string, client *http.Client) (*goquery.Document, error) {
+	resp, err := wordnikGet(ctx, wordnikPage(base, w), client)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, parseError(err, "malformed HTML from wordnik")
+	}
+	if wordnikSearchPage(resp.Request.URL) {
+		// Redirected to search results, so the word isn't a headword
+		return nil, &notFoundError{suggestions: wordnikSuggestions(doc, w)}
+	}
+	return doc, nil
+}
+
+// wordnikSearchPage reports whether u is a wordnik search results page rather
+// than a word page, which wordnik redirects to for some words it doesn't have.
+func wordnikSearchPage(u *url.URL) bool {
+	return u.Path == "/search" || strings.HasPrefix(u.Path, "/search/") || u.Query().Get("query") != ""
+}
+
+// maxSuggestions is the most similar words suggested when a word isn't found.
+const maxSuggestions = 5
+
+// wordnikSuggestions returns the first few words linked to from a wordnik search
+// results page, leaving out w itself.
+func wordnikSuggestions(doc *goquery.Document, w string) []string {
+	suggestions := make([]string, 0)
+	seen := map[string]bool{w: true}
+	doc.Find(`a[href^="/words/"]`).Each(func(i int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		s, err := url.PathUnescape(strings.TrimPrefix(href, "/words/"))
+		if err != nil || s == "" || seen[s] || len(suggestions) == maxSuggestions {
+			return
+		}
+		seen[s] = true
+		suggestions = append(suggestions, s)
+	})
+	return suggestions
+}
+
+// wordnikRelated returns the related word groups from a wordnik page, like synonyms and antonyms.
+func wordnikRelated(doc *goquery.Document) []relation {
+	ret := make([]relation, 0)
+	doc.Find(".word-module.module-related#relate .related-group").Each(func(i int, group *goquery.Selection) {
+		kind := strings.TrimSpace(group.Find("h3").First().Text())
+		words := make([]string, 0)
+		group.Find("li a").Each(func(j int, a *goquery.Selection) {
+			if t := strings.TrimSpace(a.Text()); t != "" {
+				words = append(words, t)
+			}
+		})
+		if kind != "" && len(words) > 0 {
+			ret = append(ret, relation{kind: kind, words: words})
+		}
+	})
+	return ret
+}
+
+// wordnikThesaurus returns an entry with only the related words for the provided word.
+// The definitions aren't parsed at all.
+func wordnikThesaurus(ctx context.Context, base, w string, client *http.Client) (*entry, error) {
+	doc, err := wordnikFetch(ctx, base, w, client)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{word: w, url: wordnikPage(base, w), related: wordnikRelated(doc)}, nil
+}
+
+// wordnikDictName returns the name of the dictionary from its heading on a wordnik page.
+func wordnikDictName(h *goquery.Selection) string {
+	d := ""
+	if h.Length() > 0 && h.Get(0).FirstChild != nil {
+		d = h.Get(0).FirstChild.Data
+	}
+	d = strings.TrimPrefix(strings.TrimSpace(d), "from ")
+	d = capitalize(d)
+	return strings.TrimSuffix(d, ".") // Remove ending period
+}
+
+// wordnikUnknownDict is the dictionary name of definition lists on a wordnik page
+// that don't have a heading to take the name from.
+const wordnikUnknownDict = "Wordnik"
+
+// wordnikDefsSelector selects the block of definitions on a wordnik page.
+const wordnikDefsSelector = ".word-module.module-definitions#define .guts.active"
+
+// ErrMalformedHTML is returned when a wordnik page doesn't have the parts go-dict
+// reads definitions from, which probably means wordnik has changed its layout.
+var ErrMalformedHTML = errors.New("wordnik's page isn't laid out as expected")
+
+// wordnikDefBlocks returns the active blocks of definitions on a wordnik page.
+// There's usually one, but all of them are used in case the layout changes, except
+// for ones that are copies of an earlier block. No blocks at all is ErrMalformedHTML.
+func wordnikDefBlocks(doc *goquery.Document) ([]*goquery.Selection, error) {
+	blocks := make([]*goquery.Selection, 0, 1)
+	seen := make(map[string]bool)
+	doc.Find(wordnikDefsSelector).Each(func(i int, b *goquery.Selection) {
+		html, _ := b.Html()
+		if !seen[html] {
+			seen[html] = true
+			blocks = append(blocks, b)
+		}
+	})
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%w, it has no definitions section", ErrMalformedHTML)
+	}
+	if len(blocks) > 1 {
+		debugLog.Printf("wordnik page has %d blocks of definitions, using them all", len(blocks))
+	}
+	return blocks, nil
+}
+
+// wordnikLabelSelector selects the usage labels of a definition on a wordnik page.
+const wordnikLabelSelector = ".label"
+
+// usageLabels are the usage labels that dictionaries put in italics, where the
+// part of a word type like "transitive" usually is.
+var usageLabels = map[string]bool{
+	"archaic":         true,
+	"obsolete":        true,
+	"dated":           true,
+	"rare":            true,
+	"slang":           true,
+	"informal":        true,
+	"colloquial":      true,
+	"vulgar":          true,
+	"offensive":       true,
+	"derogatory":      true,
+	"formal":          true,
+	"literary":        true,
+	"poetic":          true,
+	"humorous":        true,
+	"figurative":      true,
+	"nonstandard":     true,
+	"dialect":         true,
+	"regional":        true,
+	"british":         true,
+	"chiefly british": true,
+	"us":              true,
+	"chiefly us":      true,
+	// Phrases and idioms
+	"idiomatic":   true,
+	"euphemistic": true,
+	"proverbial":  true,
+}
+
+// wordnikLabels returns the usage labels of a definition on a wordnik page, like
+// "archaic" or "slang", and removes them from it. Labels are the elements matched
+// by wordnikLabelSelector, and the first italic text if it's one of usageLabels and
+// it's at the start of the definition, after the abbr of the word type if there is one.
+// Italics later in the text, like "Used in <i>formal</i> writing", are left alone.
+func wordnikLabels(def *goquery.Selection) []string {
+	labels := make([]string, 0)
+	def.Find(wordnikLabelSelector).Each(func(i int, l *goquery.Selection) {
+		if t := strings.TrimSpace(cleanText(l.Text())); t != "" {
+			labels = append(labels, t)
+		}
+	}).Remove()
+	text := strings.Join(strings.Fields(cleanText(def.Text())), " ")
+	text = stripWordType(text, cleanText(def.Find("abbr").First().Text()))
+	i := def.Find("i").First()
+	t := strings.TrimSpace(cleanText(i.Text()))
+	if usageLabels[strings.ToLower(strings.TrimSuffix(t, "."))] && stripWordType(text, t) != text {
+		labels = append(labels, strings.TrimSuffix(t, "."))
+		i.Remove()
+	}
+	return labels
+}
+
+// wordnikExists reports whether wordnik has any definitions for the word,
+// without parsing them.
+func wordnikExists(ctx context.Context, base, w string, client *http.Client) (bool, error) {
+	doc, err := wordnikFetch(ctx, base, w, client)
+	if errors.Is(err, ErrWordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	blocks, err := wordnikDefBlocks(doc)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range blocks {
+		if b.Find("li").Length() > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// wordnikLookup returns an entry with the ctxDefinitions for the provided word.
+// Looks up words using wordnik.com
+func wordnikLookup(ctx context.Context, base, w string, client *http.Client) (*entry, error) {
+	doc, err := wordnikFetch(ctx, base, w, client)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := wordnikDefBlocks(doc)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]ctxDefinition, 0)
+	// The headings and lists of all the blocks, numbered in page order
+	var dicts, lists []*goquery.Selection
+	for _, b := range blocks {
+		b.Find("h3").Each(func(i int, h *goquery.Selection) {
+			dicts = append(dicts, h)
+		})
+		b.Find("ul").Each(func(i int, list *goquery.Selection) {
+			lists = append(lists, list)
+		})
+	}
+	if len(dicts) != len(lists) {
+		debugLog.Printf("wordnik page for %q has %d dictionary headings but %d definition lists", w, len(dicts), len(lists))
+	}
+	// Each list has a heading, except when the page doesn't have enough of them.
+	// The lists without one are put together under wordnikUnknownDict.
+	headings := make([]*goquery.Selection, len(lists))
+	names := make([]string, len(lists))
+	// A dictionary with several homographs of the word has a heading and list for each
+	count := make(map[string]int)
+	for i := range lists {
+		names[i] = wordnikUnknownDict
+		if i < len(dicts) {
+			headings[i] = dicts[i]
+			names[i] = wordnikDictName(dicts[i])
+			count[names[i]]++
+		}
+	}
+	seen := make(map[string]int)
+	ranks := make(map[string]int) // The rank the next list of each group starts at
+	// Go through each list of defs., then each def., and add them
+	for i, list := range lists {
+		d := names[i]
+		homograph := 0
+		if count[d] > 1 {
+			seen[d]++
+			homograph = seen[d]
+		}
+		group := (&ctxDefinition{dict: d, homograph: homograph}).groupName()
+		first := ranks[group]
+		// Link to the dictionary's own entry if wordnik has one, otherwise the wordnik page
+		src, attribution, heading := wordnikPage(base, w), "", -1
+		if headings[i] != nil {
+			heading = i
+			if href, ok := headings[i].Find("a").Attr("href"); ok && strings.HasPrefix(href, "http") {
+				src = href
+			}
+			attribution = strings.TrimSpace(cleanText(headings[i].Text()))
+		}
+		list.Find("li").Each(func(j int, def *goquery.Selection) {
+			def = def.Clone()
+			// usage labels - these are removed from the definition text, before they can be taken for the wordType
+			label := strings.Join(wordnikLabels(def), ", ")
+			// examples - these are removed from the definition text, before italics in them can be taken for the wordType
+			examples := make([]string, 0)
+			def.Find(".ex").Each(func(k int, ex *goquery.Selection) {
+				if e := strings.TrimSpace(cleanText(ex.Text())); e != "" {
+					examples = append(examples, e)
+				}
+			}).Remove()
+			// wordType and the definition text after it
+			wT, t := wordnikWordType(def)
+			t = capitalize(t)
+			ret = append(ret, ctxDefinition{
+				dict:        d,
+				rank:        first + j,
+				sourceURL:   src,
+				homograph:   homograph,
+				attribution: attribution,
+				def: definition{
+					wordType: wT,
+					label:    label,
+					text:     t,
+					examples: examples,
+					origin:   &origin{heading: heading, list: i, rank: j},
+				},
+			})
+		})
+		ranks[group] += list.Find("li").Length()
+	}
+	return &entry{
+		word:    w,
+		url:     wordnikPage(base, w),
+		formOf:  findFormOf(w, ret),
+		defs:    ret,
+		related: wordnikRelated(doc),
+	}, nil
+}
+
+// wordnikSite returns the URL of wordnik's home page, from the URL of its word pages.
+func wordnikSite(base string) string {
+	return strings.TrimSuffix(base, "words/")
+}
+
+// wordnikRandom returns a random word, from the page wordnik redirects to a random word's page from.
+func wordnikRandom(ctx context.Context, base string, client *http.Client) (string, error) {
+	resp, err := wordnikGet(ctx, wordnikSite(base)+"randoword", client)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	w, err := url.PathUnescape(path.Base(resp.Request.URL.EscapedPath()))
+	if err != nil || !strings.Contains(resp.Request.URL.Path, "/words/") {
+		return "", errors.New("wordnik didn't redirect to a word")
+	}
+	return w, nil
+}
+
+// wordnikWOTD returns today's word of the day from wordnik.
+func wordnikWOTD(ctx context.Context, base string, client *http.Client) (string, error) {
+	resp, err := wordnikGet(ctx, wordnikSite(base)+"word-of-the-day", client)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return "", parseError(err, "malformed HTML from wordnik")
+	}
+	w := strings.TrimSpace(doc.Find(".word_of_the_day h1").First().Text())
+	if w == "" {
+		return "", errors.New("couldn't find the word of the day on wordnik's page")
+	}
+	return w, nil
+}
+
+// wordnik is the Source that scrapes wordnik.com.
+type wordnik struct {
+	client  *http.Client
+	baseURL string
+}
+
+func (wn *wordnik) Name() string {
+	return "wordnik"
+}
+
+func (wn *wordnik) Lookup(ctx context.Context, w string) (*entry, error) {
+	return wordnikLookup(ctx, wn.baseURL, w, wn.client)
+}
+
+func (wn *wordnik) Thesaurus(ctx context.Context, w string) (*entry, error) {
+	return wordnikThesaurus(ctx, wn.baseURL, w, wn.client)
+}
+
+func (wn *wordnik) PlannedRequests(w string) []string {
+	return []string{wordnikPage(wn.baseURL, w)}
+}
+
+func (wn *wordnik) RandomWord(ctx context.Context) (string, error) {
+	return wordnikRandom(ctx, wn.baseURL, wn.client)
+}
+
+func (wn *wordnik) WordOfTheDay(ctx context.Context) (string, error) {
+	return wordnikWOTD(ctx, wn.baseURL, wn.client)
+}
+
+func (wn *wordnik) Exists(ctx context.Context, w string) (bool, error) {
+	return wordnikExists(ctx, wn.baseURL, w, wn.client)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// wordnikFixture looks up the word on a wordnik server with the fixture page for it.
+func wordnikFixture(t *testing.T, page, w string) (*entry, error) {
+	t.Helper()
+	ts := wordnikServer(t, map[string]string{w: page})
+	return wordnikLookup(context.Background(), ts.URL+"/words/", w, ts.Client())
+}
+
+// wantDef is what a parsed definition should be.
+type wantDef struct {
+	dict     string
+	wordType string
+	label    string
+	text     string
+}
+
+// checkDefs compares the definitions of the entry to the ones wanted, in order.
+func checkDefs(t *testing.T, e *entry, want []wantDef) {
+	t.Helper()
+	if len(e.defs) != len(want) {
+		t.Errorf("got %d definitions, want %d", len(e.defs), len(want))
+	}
+	for i, cD := range e.defs {
+		if i >= len(want) {
+			break
+		}
+		got := wantDef{cD.dict, cD.def.wordType, cD.def.label, cD.def.text}
+		if got != want[i] {
+			t.Errorf("definition %d is %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestWordnikWordType(t *testing.T) {
+	e, err := wordnikFixture(t, "word-type.html", "cross")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDefs(t, e, []wantDef{
+		{"Wiktionary", "v. transitive", "", "To carry across."},
+		// Not at the start, so it's part of the text
+		{"Wiktionary", "", "", "To go across a river or stream."},
+		{"Wiktionary", "n.", "", "A crossing."},
+		{"Wiktionary", "v. intransitive", "", "To cross over."},
+	})
+}
+
+func TestStripWordType(t *testing.T) {
+	tests := []struct {
+		text, wT, want string
+	}{
+		{"v. To go.", "v.", "To go."},
+		{"v.   transitive\n To go.", "v. transitive", "To go."},
+		{"To go, v.", "v.", "To go, v."},
+		{"To go.", "", "To go."},
+		{"", "v.", ""},
+	}
+	for _, tt := range tests {
+		if got := stripWordType(tt.text, tt.wT); got != tt.want {
+			t.Errorf("stripWordType(%q, %q) = %q, want %q", tt.text, tt.wT, got, tt.want)
+		}
+	}
+}
+
+func TestWordnikEntities(t *testing.T) {
+	e, err := wordnikFixture(t, "entities.html", "dictionary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDefs(t, e, []wantDef{
+		// Soft hyphens in words, as entities and as they are
+		{"Wiktionary", "n.", "informal", "A dictionary of slang."},
+		// Entities that were escaped twice
+		{"Wiktionary", "n. transitive", "", `Words & phrases, like "R&D".`},
+		{"Wiktionary", "n.", "", "A list of words & their meanings."},
+	})
+}
+
+func TestWordnikSearchRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/words/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/search?query=colour", http.StatusFound)
+	})
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture(t, "wordnik/search.html"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	_, err := wordnikLookup(context.Background(), ts.URL+"/words/", "colour", ts.Client())
+	if !errors.Is(err, ErrWordNotFound) {
+		t.Fatalf("error is %v, want ErrWordNotFound", err)
+	}
+	var nf *notFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("error is %v, want a notFoundError", err)
+	}
+	// Without the word itself, or repeats
+	want := []string{"color", "colours", "colour bar"}
+	if !reflect.DeepEqual(nf.suggestions, want) {
+		t.Errorf("suggestions are %q, want %q", nf.suggestions, want)
+	}
+
+	stdout, stderr, code := runWordnik(t, ts, "colour")
+	if stdout != "" || code != 1 {
+		t.Errorf("run wrote %q and exited with %d, want nothing and 1", stdout, code)
+	}
+	if want := "go-dict: colour: word not found, did you mean: color, colours, colour bar\n"; stderr != want {
+		t.Errorf("stderr is %q, want %q", stderr, want)
+	}
+}
+
+func TestWordnikSingleCharacter(t *testing.T) {
+	e, err := wordnikFixture(t, "a.html", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDefs(t, e, []wantDef{
+		{"Wiktionary", "n.", "", "The first letter of the English alphabet."},
+		{"Wiktionary", "n.", "", "A"},
+		{"Wiktionary", "", "", "É"},
+		// Only a word type, with nothing after it
+		{"Wiktionary", "n.", "", ""},
+	})
+
+	ts := wordnikServer(t, map[string]string{"a": "a.html"})
+	stdout, stderr, code := runWordnik(t, ts, "a")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	want := "a\nWiktionary\nn.  The first letter of the English alphabet.\nn.  A\n    É\nn.  \n\n"
+	if stdout != want {
+		t.Errorf("stdout is %q, want %q", stdout, want)
+	}
+}
+
+func TestWordnikBlocks(t *testing.T) {
+	_, err := wordnikFixture(t, "no-blocks.html", "stone")
+	if !errors.Is(err, ErrMalformedHTML) {
+		t.Errorf("with no active blocks the error is %v, want ErrMalformedHTML", err)
+	}
+
+	e, err := wordnikFixture(t, "duplicate-blocks.html", "stone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A copy of a block isn't used again
+	checkDefs(t, e, []wantDef{
+		{"Wiktionary", "n.", "", "A heavy, hard object."},
+		{"Wiktionary", "v.", "", "To throw stones at."},
+	})
+
+	e, err = wordnikFixture(t, "extra-blocks.html", "stone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDefs(t, e, []wantDef{
+		{"Wiktionary", "n.", "", "A heavy, hard object."},
+		{"The Century Dictionary", "n.", "", "A concretion of earthy or mineral matter."},
+		{"The Century Dictionary", "n.", "", "A weight of fourteen pounds."},
+	})
+	for i, cD := range e.defs {
+		if want := []int{0, 0, 1}[i]; cD.rank != want {
+			t.Errorf("definition %d has rank %d, want %d", i, cD.rank, want)
+		}
+	}
+
+	ts := wordnikServer(t, map[string]string{"stone": "no-blocks.html"})
+	exists, err := wordnikExists(context.Background(), ts.URL+"/words/", "stone", ts.Client())
+	if exists || !errors.Is(err, ErrMalformedHTML) {
+		t.Errorf("wordnikExists with no active blocks is %v, %v, want false and ErrMalformedHTML", exists, err)
+	}
+}
+
+func TestWordnikMismatchedHeadings(t *testing.T) {
+	e, err := wordnikFixture(t, "more-lists.html", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The lists without a heading are put together
+	checkDefs(t, e, []wantDef{
+		{"Wiktionary", "n.", "", "A body of running water."},
+		{wordnikUnknownDict, "n.", "", "A list without a heading."},
+		{wordnikUnknownDict, "v.", "", "Another list without a heading."},
+		{wordnikUnknownDict, "v.", "", "With two definitions."},
+	})
+	for i, cD := range e.defs {
+		if want := []int{0, 0, 1, 2}[i]; cD.rank != want {
+			t.Errorf("definition %d has rank %d, want %d", i, cD.rank, want)
+		}
+	}
+	if e.defs[1].attribution != "" || e.defs[1].sourceURL != e.url {
+		t.Errorf("a list without a heading has attribution %q and links to %q, want none and the page",
+			e.defs[1].attribution, e.defs[1].sourceURL)
+	}
+
+	e, err = wordnikFixture(t, "more-headings.html", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The headings without a list are left out
+	checkDefs(t, e, []wantDef{
+		{"Wiktionary", "n.", "", "A body of running water."},
+	})
+}
+
+func TestWordnikLongList(t *testing.T) {
+	var page strings.Builder
+	page.WriteString(`<div class="word-module module-definitions" id="define"><div class="guts active">`)
+	page.WriteString(`<h3 class="source">from Wiktionary</h3><ul>`)
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&page, "<li><abbr>n.</abbr> Sense %d.</li>", i)
+	}
+	page.WriteString(`</ul><h3 class="source">from Wiktionary</h3><ul><li><abbr>v.</abbr> One more.</li></ul>`)
+	page.WriteString(`</div></div>`)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page.String()))
+	}))
+	defer ts.Close()
+	e, err := wordnikLookup(context.Background(), ts.URL+"/words/", "set", ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.defs) != 301 {
+		t.Fatalf("got %d definitions, want 301", len(e.defs))
+	}
+	// Ranks don't wrap around after 255
+	for i, cD := range e.defs[:300] {
+		if cD.rank != i {
+			t.Fatalf("definition %d has rank %d", i, cD.rank)
+		}
+	}
+	// The second homograph's list starts its own ranks
+	if cD := e.defs[300]; cD.homograph != 2 || cD.rank != 0 {
+		t.Errorf("the second homograph's definition is homograph %d with rank %d, want 2 and 0", cD.homograph, cD.rank)
+	}
+}
+
+func TestWordnikPhrase(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		if r.URL.Path != "/words/kick the bucket" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(fixture(t, "wordnik/kick-the-bucket.html"))
+	}))
+	defer ts.Close()
+
+	stdout, stderr, code := runWordnik(t, ts, "--examples", "kick  the   bucket")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	if want := []string{"/words/kick%20the%20bucket"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested %q, want %q", paths, want)
+	}
+	want := "kick the bucket\n" +
+		"The American Heritage® Idioms Dictionary\n" +
+		"  (slang) To die.\n" +
+		"    Uncle Bill kicked the bucket last year.\n" +
+		"\n" +
+		"Wiktionary\n" +
+		"v.  (idiomatic) To die.\n\n"
+	if stdout != want {
+		t.Errorf("stdout is\n%s\nwant\n%s", stdout, want)
+	}
+}
+
+func TestWordnikPage(t *testing.T) {
+	tests := []struct {
+		w, want string
+	}{
+		{"run", "https://www.wordnik.com/words/run"},
+		{"kick the bucket", "https://www.wordnik.com/words/kick%20the%20bucket"},
+		{" kick  the\tbucket ", "https://www.wordnik.com/words/kick%20the%20bucket"},
+		{"AC/DC", "https://www.wordnik.com/words/AC%2FDC"},
+		{"café?", "https://www.wordnik.com/words/caf%C3%A9%3F"},
+	}
+	for _, tt := range tests {
+		if got := wordnikPage(wordnikURL, tt.w); got != tt.want {
+			t.Errorf("wordnikPage(%q) = %q, want %q", tt.w, got, tt.want)
+		}
+	}
+}
+
+func TestWordnikWordTypeParts(t *testing.T) {
+	e, err := wordnikFixture(t, "word-type-parts.html", "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDefs(t, e, []wantDef{
+		// Only an abbr, or only an i
+		{"Wiktionary", "v.", "", "To look at and understand writing."},
+		{"Wiktionary", "transitive", "", "To speak aloud what's written."},
+		// Italics and abbreviations later in the text are part of it
+		{"Wiktionary", "v.", "", "To study Hamlet at school."},
+		{"Wiktionary", "", "", "To interpret e.g. a situation."},
+		// Only a leading italic usage label is a label
+		{"Wiktionary", "adj.", "", "Used in formal writing."},
+		{"Wiktionary", "n.", "Slang", "Someone who reads a lot."},
+		{"Wiktionary", "", "archaic", "Advice."},
+	})
+}
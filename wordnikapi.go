@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// wordnikAPIURL is the default URL of the Wordnik API.
+const wordnikAPIURL = "https://api.wordnik.com/v4/"
+
+// wordnikAPIDicts maps the sourceDictionary values of the Wordnik API to the names
+// the wordnik website uses for them.
+var wordnikAPIDicts = map[string]string{
+	"ahd-5":      "The American Heritage® Dictionary of the English Language, 5th Edition",
+	"ahd":        "The American Heritage® Dictionary of the English Language, 4th Edition",
+	"century":    "The Century Dictionary and Cyclopedia",
+	"gcide":      "GNU version of the Collaborative International Dictionary of English",
+	"wiktionary": "Wiktionary, Creative Commons Attribution/Share-Alike License",
+	"wordnet":    "WordNet 3.0 Copyright 2006 by Princeton University. All rights reserved",
+}
+
+// apiDefinition is a definition as returned by the Wordnik API.
+type apiDefinition struct {
+	Text             string `json:"text"`
+	PartOfSpeech     string `json:"partOfSpeech"`
+	SourceDictionary string `json:"sourceDictionary"`
+	AttributionURL   string `json:"attributionUrl"`
+	AttributionText  string `json:"attributionText"`
+	ExampleUses      []struct {
+		Text string `json:"text"`
+	} `json:"exampleUses"`
+	RelatedWords []struct {
+		RelationshipType string   `json:"relationshipType"`
+		Words            []string `json:"words"`
+	} `json:"relatedWords"`
+	Labels []struct {
+		Text string `json:"text"`
+	} `json:"labels"`
+}
+
+// tagRe matches the XML tags the Wordnik API puts in definition text, like <xref>.
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// wordnikAPIDefsURL returns the URL of the request to the definitions endpoint for
+// the word. The API key is left out if it's empty.
+func wordnikAPIDefsURL(base, key, w string) string {
+	q := url.Values{}
+	q.Set("limit", "200")
+	q.Set("includeRelated", "true")
+	q.Set("useCanonical", "false")
+	if key != "" {
+		q.Set("api_key", key)
+	}
+	return base + "word.json/" + url.PathEscape(w) + "/definitions?" + q.Encode()
+}
+
+// wordnikAPILookup returns an entry for the word using the Wordnik API.
+// Definitions, examples and related words all come from a single request to
+// the definitions endpoint, which includes the others with includeRelated.
+// page is the URL of wordnik's word pages, which the entry links to.
+func wordnikAPILookup(ctx context.Context, base, page, key, w string, client *http.Client) (*entry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", wordnikAPIDefsURL(base, key, w), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(ctx, client, req)
+	if errors.Is(err, ErrRateLimited) || (err != nil && ctx.Err() != nil) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w to the Wordnik API: %s", ErrNetwork, connectProblem(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrWordNotFound
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("the Wordnik API returned %s", resp.Status)
+	}
+	var apiDefs []apiDefinition
+	if err := json.NewDecoder(resp.Body).Decode(&apiDefs); err != nil {
+		return nil, parseError(err, "malformed JSON from the Wordnik API")
+	}
+
+	e := &entry{word: w, url: wordnikPage(page, w), defs: make([]ctxDefinition, 0, len(apiDefs))}
+	ranks := make(map[string]int)
+	relIndex := make(map[string]int)
+	for _, ad := range apiDefs {
+		text := strings.TrimSpace(cleanText(tagRe.ReplaceAllString(ad.Text, "")))
+		for _, rw := range ad.RelatedWords {
+			key := rw.RelationshipType + "\x00" + ad.PartOfSpeech
+			i, ok := relIndex[key]
+			if !ok {
+				i = len(e.related)
+				relIndex[key] = i
+				e.related = append(e.related, relation{kind: rw.RelationshipType, wordType: ad.PartOfSpeech})
+			}
+			e.related[i].words = append(e.related[i].words, rw.Words...)
+		}
+		if text == "" {
+			// Some entries are only there for their related words
+			continue
+		}
+		dict, ok := wordnikAPIDicts[ad.SourceDictionary]
+		if !ok {
+			dict = ad.SourceDictionary
+		}
+		examples := make([]string, 0, len(ad.ExampleUses))
+		for _, ex := range ad.ExampleUses {
+			examples = append(examples, cleanText(tagRe.ReplaceAllString(ex.Text, "")))
+		}
+		labels := make([]string, 0, len(ad.Labels))
+		for _, l := range ad.Labels {
+			if t := strings.TrimSpace(cleanText(l.Text)); t != "" {
+				labels = append(labels, t)
+			}
+		}
+		src := ad.AttributionURL
+		if src == "" {
+			src = e.url
+		}
+		e.defs = append(e.defs, ctxDefinition{
+			dict:        dict,
+			rank:        ranks[dict],
+			sourceURL:   src,
+			attribution: strings.TrimSpace(cleanText(ad.AttributionText)),
+			def: definition{
+				wordType: ad.PartOfSpeech,
+				label:    strings.Join(labels, ", "),
+				text:     text,
+				examples: examples,
+			},
+		})
+		ranks[dict]++
+	}
+	e.formOf = findFormOf(w, e.defs)
+	return e, nil
+}
+
+// wordnikAPISearch returns up to limit words starting with prefix, using the
+// search endpoint of the Wordnik API.
+func wordnikAPISearch(ctx context.Context, base, key, prefix string, limit int, client *http.Client) ([]string, error) {
+	q := url.Values{}
+	q.Set("caseSensitive", "false")
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("api_key", key)
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"words.json/search/"+url.PathEscape(prefix+"*")+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(ctx, client, req)
+	if errors.Is(err, ErrRateLimited) || (err != nil && ctx.Err() != nil) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w to the Wordnik API: %s", ErrNetwork, connectProblem(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("the Wordnik API returned %s", resp.Status)
+	}
+	var results struct {
+		SearchResults []struct {
+			Word string `json:"word"`
+		} `json:"searchResults"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, parseError(err, "malformed JSON from the Wordnik API")
+	}
+	words := make([]string, 0, len(results.SearchResults))
+	for _, r := range results.SearchResults {
+		words = append(words, r.Word)
+	}
+	return words, nil
+}
+
+// wordnikAPIWord returns the word from an endpoint of the Wordnik API that
+// responds with a single word, like words.json/randomWord.
+func wordnikAPIWord(ctx context.Context, base, key, endpoint string, q url.Values, client *http.Client) (string, error) {
+	q.Set("api_key", key)
+	req, err := http.NewRequestWithContext(ctx, "GET", base+endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := doRequest(ctx, client, req)
+	if errors.Is(err, ErrRateLimited) || (err != nil && ctx.Err() != nil) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w to the Wordnik API: %s", ErrNetwork, connectProblem(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("the Wordnik API returned %s", resp.Status)
+	}
+	var result struct {
+		Word string `json:"word"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", parseError(err, "malformed JSON from the Wordnik API")
+	}
+	if result.Word == "" {
+		return "", errors.New("malformed JSON from the Wordnik API")
+	}
+	return result.Word, nil
+}
+
+// wordnikAPIReverse returns up to limit words whose definitions match the query,
+// using the reverse dictionary endpoint of the Wordnik API, most relevant first.
+func wordnikAPIReverse(ctx context.Context, base, key, query string, limit int, client *http.Client) ([]string, error) {
+	q := url.Values{}
+	q.Set("query", query)
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("api_key", key)
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"words.json/reverseDictionary?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(ctx, client, req)
+	if errors.Is(err, ErrRateLimited) || (err != nil && ctx.Err() != nil) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w to the Wordnik API: %s", ErrNetwork, connectProblem(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("the Wordnik API returned %s", resp.Status)
+	}
+	var results struct {
+		Results []struct {
+			Word string `json:"word"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, parseError(err, "malformed JSON from the Wordnik API")
+	}
+	words := make([]string, 0, len(results.Results))
+	seen := make(map[string]bool)
+	for _, r := range results.Results {
+		// There's a result for each matching definition, so words can be repeated
+		if r.Word != "" && !seen[r.Word] {
+			seen[r.Word] = true
+			words = append(words, r.Word)
+		}
+	}
+	return words, nil
+}
+
+// wordnikAPIFrequency returns how many times the word is used in Wordnik's corpus,
+// using the frequency endpoint of the Wordnik API.
+func wordnikAPIFrequency(ctx context.Context, base, key, w string, client *http.Client) (int, error) {
+	q := url.Values{}
+	q.Set("useCanonical", "false")
+	q.Set("api_key", key)
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"word.json/"+url.PathEscape(w)+"/frequency?"+q.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := doRequest(ctx, client, req)
+	if errors.Is(err, ErrRateLimited) || (err != nil && ctx.Err() != nil) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w to the Wordnik API: %s", ErrNetwork, connectProblem(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, ErrWordNotFound
+	}
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("the Wordnik API returned %s", resp.Status)
+	}
+	var result struct {
+		TotalCount int `json:"totalCount"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, parseError(err, "malformed JSON from the Wordnik API")
+	}
+	return result.TotalCount, nil
+}
+
+// wordnikAPI is the Source that uses the Wordnik API, which needs an API key.
+type wordnikAPI struct {
+	client  *http.Client
+	baseURL string
+	pageURL string // The URL of wordnik's word pages, for linking to them
+}
+
+func (wa *wordnikAPI) Name() string {
+	return "wordnik-api"
+}
+
+func (wa *wordnikAPI) Lookup(ctx context.Context, w string) (*entry, error) {
+	key, err := apiKey("wordnik")
+	if err != nil {
+		return nil, err
+	}
+	return wordnikAPILookup(ctx, wa.baseURL, wa.pageURL, key, w, wa.client)
+}
+
+func (wa *wordnikAPI) Thesaurus(ctx context.Context, w string) (*entry, error) {
+	return wa.Lookup(ctx, w)
+}
+
+func (wa *wordnikAPI) PlannedRequests(w string) []string {
+	return []string{wordnikAPIDefsURL(wa.baseURL, "", w)}
+}
+
+func (wa *wordnikAPI) Complete(ctx context.Context, prefix string) ([]string, error) {
+	key, err := apiKey("wordnik")
+	if err != nil {
+		return nil, err
+	}
+	return wordnikAPISearch(ctx, wa.baseURL, key, prefix, 20, wa.client)
+}
+
+func (wa *wordnikAPI) RandomWord(ctx context.Context) (string, error) {
+	key, err := apiKey("wordnik")
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("hasDictionaryDef", "true")
+	return wordnikAPIWord(ctx, wa.baseURL, key, "words.json/randomWord", q, wa.client)
+}
+
+func (wa *wordnikAPI) WordOfTheDay(ctx context.Context) (string, error) {
+	key, err := apiKey("wordnik")
+	if err != nil {
+		return "", err
+	}
+	return wordnikAPIWord(ctx, wa.baseURL, key, "words.json/wordOfTheDay", url.Values{}, wa.client)
+}
+
+func (wa *wordnikAPI) Reverse(ctx context.Context, query string, limit int) ([]string, error) {
+	key, err := apiKey("wordnik")
+	if err != nil {
+		return nil, err
+	}
+	return wordnikAPIReverse(ctx, wa.baseURL, key, query, limit, wa.client)
+}
+
+func (wa *wordnikAPI) Frequency(ctx context.Context, w string) (int, error) {
+	key, err := apiKey("wordnik")
+	if err != nil {
+		return 0, err
+	}
+	return wordnikAPIFrequency(ctx, wa.baseURL, key, w, wa.client)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWordnikAPIPhrase(t *testing.T) {
+	var path, key string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.EscapedPath(), r.URL.Query().Get("api_key")
+		w.Write([]byte(`[{"text":"To die.","partOfSpeech":"idiom","sourceDictionary":"ahd-5"}]`))
+	}))
+	defer ts.Close()
+	setenv(t, envName("wordnik-api-key"), "test-key")
+	urls := map[string]string{"wordnik-api": ts.URL + "/v4/", "wordnik": "https://wordnik.example.com/words/"}
+	src, err := newSource("wordnik-api", ts.Client(), urls, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := src.Lookup(context.Background(), "kick the bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/v4/word.json/kick%20the%20bucket/definitions"; path != want || key != "test-key" {
+		t.Errorf("requested %s with key %q, want %s with test-key", path, key, want)
+	}
+	// The link is to the page on the overridden wordnik instead of the real one, escaped
+	if want := "https://wordnik.example.com/words/kick%20the%20bucket"; e.url != want {
+		t.Errorf("the entry links to %q, want %q", e.url, want)
+	}
+	if len(e.defs) != 1 || e.defs[0].def.text != "To die." || e.defs[0].sourceURL != e.url {
+		t.Errorf("definitions are %+v, want one that links to the page", e.defs)
+	}
+}
+
+func TestWordnikAPIKeyNotInErrors(t *testing.T) {
+	// Closing the connection without a response is an error connectProblem doesn't
+	// classify, so its message is the request's error, with the URL in it
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer ts.Close()
+	setenv(t, envName("wordnik-api-key"), "secret-key")
+	src, err := newSource("wordnik-api", ts.Client(), map[string]string{"wordnik-api": ts.URL + "/v4/"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wa := src.(*wordnikAPI)
+	ctx := context.Background()
+	calls := map[string]func() error{
+		"Lookup":       func() error { _, err := wa.Lookup(ctx, "run"); return err },
+		"Complete":     func() error { _, err := wa.Complete(ctx, "ru"); return err },
+		"RandomWord":   func() error { _, err := wa.RandomWord(ctx); return err },
+		"WordOfTheDay": func() error { _, err := wa.WordOfTheDay(ctx); return err },
+		"Reverse":      func() error { _, err := wa.Reverse(ctx, "fear of heights", 10); return err },
+		"Frequency":    func() error { _, err := wa.Frequency(ctx, "run"); return err },
+	}
+	for name, call := range calls {
+		err := call()
+		if err == nil {
+			t.Errorf("%s: no error", name)
+			continue
+		}
+		if strings.Contains(err.Error(), "secret-key") {
+			t.Errorf("%s: the API key is in the error %q", name, err)
+		}
+	}
+}